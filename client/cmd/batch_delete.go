@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(batchDeleteCommand)
+}
+
+var batchDeleteCommand = &cobra.Command{
+	Use:   "batch-delete [timer uuids]",
+	Short: "Deletes many timers.",
+	Long:  "Deletes the given timers in one request, and prints the result of each.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := services.BatchDeleteTimersRequest{
+			TimerUuids: args,
+		}
+
+		ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+		res, err := client.BatchDeleteTimers(ctx, &req)
+		if err != nil {
+			fmt.Println("err:", err)
+			return
+		}
+
+		for _, result := range res.Results {
+			if result.Error != nil {
+				fmt.Printf("%v: err: %v\n", result.TimerUuid, result.Error.Message)
+			} else {
+				fmt.Printf("%v: OK\n", result.TimerUuid)
+			}
+		}
+	},
+}
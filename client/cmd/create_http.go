@@ -2,55 +2,187 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/nivista/steady/.gen/protos/common"
 	"github.com/nivista/steady/.gen/protos/services"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func init() {
 
 	createHTTPCommand.Flags().StringVar(&cron, "cron", "@every 5s", "cron schedule for timer.")
+	createHTTPCommand.Flags().StringVar(&at, "at", "", "RFC 3339 time to fire once at, instead of the cron.")
+	createHTTPCommand.Flags().DurationVar(&interval, "interval", 0, "interval to fire at, starting now, instead of the cron.")
+	createHTTPCommand.Flags().StringVar(&rruleSpec, "rrule", "", "RFC 5545 recurrence to fire on instead of the cron, lines separated by newlines.")
+	createHTTPCommand.Flags().StringVar(&timezone, "timezone", "", "time zone the cron or rrule is evaluated in, like America/New_York (default: UTC).")
+	createHTTPCommand.Flags().StringVar(&misfirePolicy, "misfire-policy", "fire-once", "what happens to missed fires, one of fire-once, fire-all, skip or grace-window.")
+	createHTTPCommand.Flags().DurationVar(&graceWindow, "grace-window", 0, "how late a missed fire can be and still fire, for the grace-window misfire policy.")
+	createHTTPCommand.Flags().DurationVar(&jitter, "jitter", 0, "maximum random offset of each fire.")
+	createHTTPCommand.Flags().StringSliceVar(&calendars, "calendars", nil, "names of calendars whose exclusions are skipped.")
 	createHTTPCommand.Flags().IntVar(&maxExecutions, "max-executions", 5, "max executions of timer (default: 5, zero means infinite executions")
 	createHTTPCommand.Flags().StringVar(&url, "url", "http://example.com", "url endpoint you want to hit (default example.com)")
+	createHTTPCommand.Flags().StringVar(&method, "method", "GET", "http method, one of GET, POST, PUT, PATCH, DELETE, HEAD or OPTIONS.")
+	createHTTPCommand.Flags().StringVar(&body, "body", "", "body of the http request.")
+	createHTTPCommand.Flags().DurationVar(&timeout, "timeout", 0, "timeout of each request (default: zero, meaning the server default).")
+	createHTTPCommand.Flags().BoolVar(&template, "template", false, "whether the url and body are templates, with variables like {{scheduled_time}}.")
+	createHTTPCommand.Flags().StringToStringVar(&labels, "labels", nil, "labels of the timer, like env=prod,team=payments.")
+	createHTTPCommand.Flags().StringVar(&idempotencyKey, "idempotency-key", "", "key that makes retries of this create return the same timer, batches suffix it with the index of each timer.")
+	createHTTPCommand.Flags().IntVar(&count, "count", 1, "number of identical timers to create, more than one are created in a batch.")
 	createHTTPCommand.Flags().BoolVar(&includeBody, "include-body", false, "whether or not to send the body to elasticsearch.")
 
 	viper.BindPFlag("cron", createHTTPCommand.Flags().Lookup("cron"))
+	viper.BindPFlag("at", createHTTPCommand.Flags().Lookup("at"))
+	viper.BindPFlag("interval", createHTTPCommand.Flags().Lookup("interval"))
+	viper.BindPFlag("rrule", createHTTPCommand.Flags().Lookup("rrule"))
+	viper.BindPFlag("timezone", createHTTPCommand.Flags().Lookup("timezone"))
+	viper.BindPFlag("misfire-policy", createHTTPCommand.Flags().Lookup("misfire-policy"))
+	viper.BindPFlag("grace-window", createHTTPCommand.Flags().Lookup("grace-window"))
+	viper.BindPFlag("jitter", createHTTPCommand.Flags().Lookup("jitter"))
+	viper.BindPFlag("calendars", createHTTPCommand.Flags().Lookup("calendars"))
 	viper.BindPFlag("max-executions", createHTTPCommand.Flags().Lookup("max-executions"))
 	viper.BindPFlag("url", createHTTPCommand.Flags().Lookup("url"))
+	viper.BindPFlag("method", createHTTPCommand.Flags().Lookup("method"))
+	viper.BindPFlag("body", createHTTPCommand.Flags().Lookup("body"))
+	viper.BindPFlag("timeout", createHTTPCommand.Flags().Lookup("timeout"))
+	viper.BindPFlag("template", createHTTPCommand.Flags().Lookup("template"))
+	viper.BindPFlag("labels", createHTTPCommand.Flags().Lookup("labels"))
+	viper.BindPFlag("idempotency-key", createHTTPCommand.Flags().Lookup("idempotency-key"))
+	viper.BindPFlag("count", createHTTPCommand.Flags().Lookup("count"))
 	viper.BindPFlag("include-body", createHTTPCommand.Flags().Lookup("include-body"))
 
 	rootCmd.AddCommand(createHTTPCommand)
 }
 
 var (
-	cron          string
-	maxExecutions int
-	url           string
-	includeBody   bool
+	cron           string
+	at             string
+	interval       time.Duration
+	rruleSpec      string
+	timezone       string
+	misfirePolicy  string
+	graceWindow    time.Duration
+	jitter         time.Duration
+	calendars      []string
+	maxExecutions  int
+	url            string
+	method         string
+	body           string
+	timeout        time.Duration
+	template       bool
+	labels         map[string]string
+	idempotencyKey string
+	count          int
+	includeBody    bool
 
 	createHTTPCommand = &cobra.Command{
 		Use:   "create-http",
 		Short: "Creates a new http timer.",
 		Long:  "Creates a new http timer.",
 		Run: func(cmd *cobra.Command, args []string) {
+			m, ok := common.Method_value[strings.ToUpper(method)]
+			if !ok {
+				fmt.Println("unknown method:", method)
+				return
+			}
+
+			var b []byte
+			if body != "" {
+				b = []byte(body)
+			}
+
+			policy, ok := common.MisfirePolicy_value["MISFIRE_POLICY_"+strings.ToUpper(strings.ReplaceAll(misfirePolicy, "-", "_"))]
+			if !ok {
+				fmt.Println("unknown misfire policy:", misfirePolicy)
+				return
+			}
+
+			var t *durationpb.Duration
+			if timeout != 0 {
+				t = durationpb.New(timeout)
+			}
+
+			var g *durationpb.Duration
+			if graceWindow != 0 {
+				g = durationpb.New(graceWindow)
+			}
+
+			var j *durationpb.Duration
+			if jitter != 0 {
+				j = durationpb.New(jitter)
+			}
+
 			req := services.CreateTimerRequest{
 				Task: &common.Task{
 					Task: &common.Task_Http{
 						Http: &common.HTTP{
 							Url:              url,
-							Method:           common.Method_GET,
+							Method:           common.Method(m),
+							Body:             b,
+							Timeout:          t,
 							SaveResponseBody: includeBody,
+							Template:         template,
 						},
 					},
 				},
 				Schedule: &common.Schedule{
-					Cron:          cron,
-					MaxExecutions: common.Executions(maxExecutions),
+					Spec:          &common.Schedule_Cron{Cron: cron},
+					MaxExecutions: int32(maxExecutions),
+					Timezone:      timezone,
+					MisfirePolicy: common.MisfirePolicy(policy),
+					GraceWindow:   g,
+					Jitter:        j,
+					Calendars:     calendars,
 				},
+				Labels:         labels,
+				IdempotencyKey: idempotencyKey,
 			}
+
+			if rruleSpec != "" {
+				req.Schedule.Spec = &common.Schedule_Rrule{Rrule: rruleSpec}
+			} else if interval != 0 {
+				req.Schedule.Spec = &common.Schedule_Interval{Interval: durationpb.New(interval)}
+			} else if at != "" {
+				fireAt, err := time.Parse(time.RFC3339, at)
+				if err != nil {
+					fmt.Println("invalid at:", err)
+					return
+				}
+				req.Schedule.Spec = &common.Schedule_At{At: timestamppb.New(fireAt)}
+			}
+
 			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			if count > 1 {
+				batch := services.BatchCreateTimersRequest{}
+				for i := 0; i < count; i++ {
+					timerReq := proto.Clone(&req).(*services.CreateTimerRequest)
+					if idempotencyKey != "" {
+						timerReq.IdempotencyKey = fmt.Sprintf("%v-%v", idempotencyKey, i)
+					}
+					batch.Timers = append(batch.Timers, timerReq)
+				}
+
+				res, err := client.BatchCreateTimers(ctx, &batch)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+
+				for _, result := range res.Results {
+					if result.Error != nil {
+						fmt.Println("err:", result.Error.Message)
+					} else {
+						fmt.Println("ID:", result.TimerUuid)
+					}
+				}
+				return
+			}
+
 			res, err := client.CreateTimer(ctx, &req)
 			if err != nil {
 				fmt.Println(err)
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func init() {
+	executionsCommand.Flags().StringVar(&id, "id", "", "only executions of this timer.")
+	executionsCommand.Flags().IntVar(&pageSize, "page-size", 0, "executions per page (default: 50).")
+	executionsCommand.Flags().StringVar(&pageToken, "page-token", "", "token of the page, from the previous page.")
+	executionsCommand.Flags().StringVar(&since, "since", "", "RFC 3339 time of the earliest execution.")
+	executionsCommand.Flags().StringVar(&until, "until", "", "RFC 3339 time executions are before.")
+	executionsCommand.Flags().StringVar(&outcome, "outcome", "", "only executions with this outcome, one of success, failure or skipped.")
+
+	rootCmd.AddCommand(executionsCommand)
+}
+
+var (
+	since   string
+	until   string
+	outcome string
+
+	executionsCommand = &cobra.Command{
+		Use:   "executions",
+		Short: "Lists executions.",
+		Long:  "Lists a page of executions, most recent first.",
+		Run: func(cmd *cobra.Command, args []string) {
+			req := services.ListExecutionsRequest{
+				PageSize:  int32(pageSize),
+				PageToken: pageToken,
+				TimerUuid: id,
+			}
+
+			if outcome != "" {
+				o, ok := services.ExecutionOutcome_value["EXECUTION_OUTCOME_"+strings.ToUpper(outcome)]
+				if !ok {
+					fmt.Println("unknown outcome:", outcome)
+					return
+				}
+				req.Outcome = services.ExecutionOutcome(o)
+			}
+
+			if since != "" {
+				t, err := time.Parse(time.RFC3339, since)
+				if err != nil {
+					fmt.Println("invalid since:", err)
+					return
+				}
+				req.StartTime = timestamppb.New(t)
+			}
+
+			if until != "" {
+				t, err := time.Parse(time.RFC3339, until)
+				if err != nil {
+					fmt.Println("invalid until:", err)
+					return
+				}
+				req.EndTime = timestamppb.New(t)
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			res, err := client.ListExecutions(ctx, &req)
+			if err != nil {
+				fmt.Println("err:", err)
+				return
+			}
+
+			for _, e := range res.Executions {
+				fmt.Println(protojson.Format(e))
+			}
+			if res.NextPageToken != "" {
+				fmt.Println("next page token:", res.NextPageToken)
+			}
+		},
+	}
+)
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func init() {
+	getCommand.Flags().StringVar(&id, "id", "", "id of timer.")
+
+	listCommand.Flags().IntVar(&pageSize, "page-size", 0, "timers per page (default: 50).")
+	listCommand.Flags().StringVar(&pageToken, "page-token", "", "token of the page, from the previous page.")
+	listCommand.Flags().StringVar(&state, "state", "", "only timers in this state, one of active or paused.")
+	listCommand.Flags().StringToStringVar(&labels, "labels", nil, "only timers with these labels, like env=prod.")
+
+	rootCmd.AddCommand(getCommand)
+	rootCmd.AddCommand(listCommand)
+}
+
+var (
+	pageSize  int
+	pageToken string
+	state     string
+
+	getCommand = &cobra.Command{
+		Use:   "get",
+		Short: "Gets a timer.",
+		Long:  "Gets a timer, with its progress and next fire time.",
+		Run: func(cmd *cobra.Command, args []string) {
+			req := services.GetTimerRequest{
+				TimerUuid: id,
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			res, err := client.GetTimer(ctx, &req)
+			if err != nil {
+				fmt.Println("err:", err)
+			} else {
+				fmt.Println(protojson.Format(res.Timer))
+			}
+		},
+	}
+
+	listCommand = &cobra.Command{
+		Use:   "list",
+		Short: "Lists timers.",
+		Long:  "Lists a page of timers, with their progress and next fire times.",
+		Run: func(cmd *cobra.Command, args []string) {
+			var s int32
+			if state != "" {
+				var ok bool
+				s, ok = services.TimerState_value["TIMER_STATE_"+strings.ToUpper(state)]
+				if !ok {
+					fmt.Println("unknown state:", state)
+					return
+				}
+			}
+
+			req := services.ListTimersRequest{
+				PageSize:  int32(pageSize),
+				PageToken: pageToken,
+				State:     services.TimerState(s),
+				Labels:    labels,
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			res, err := client.ListTimers(ctx, &req)
+			if err != nil {
+				fmt.Println("err:", err)
+				return
+			}
+
+			for _, t := range res.Timers {
+				fmt.Println(protojson.Format(t))
+			}
+			if res.NextPageToken != "" {
+				fmt.Println("next page token:", res.NextPageToken)
+			}
+		},
+	}
+)
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	pauseCommand.Flags().StringVar(&id, "id", "", "id of timer.")
+	resumeCommand.Flags().StringVar(&id, "id", "", "id of timer.")
+
+	rootCmd.AddCommand(pauseCommand)
+	rootCmd.AddCommand(resumeCommand)
+}
+
+var (
+	pauseCommand = &cobra.Command{
+		Use:   "pause",
+		Short: "Pauses a timer.",
+		Long:  "Pauses a timer, it doesn't fire until it's resumed.",
+		Run: func(cmd *cobra.Command, args []string) {
+			req := services.PauseTimerRequest{
+				TimerUuid: id,
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			_, err := client.PauseTimer(ctx, &req)
+			if err != nil {
+				fmt.Println("err:", err)
+			} else {
+				fmt.Println("OK")
+			}
+		},
+	}
+
+	resumeCommand = &cobra.Command{
+		Use:   "resume",
+		Short: "Resumes a paused timer.",
+		Long:  "Resumes a paused timer, fires missed while it was paused follow its misfire policy.",
+		Run: func(cmd *cobra.Command, args []string) {
+			req := services.ResumeTimerRequest{
+				TimerUuid: id,
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			_, err := client.ResumeTimer(ctx, &req)
+			if err != nil {
+				fmt.Println("err:", err)
+			} else {
+				fmt.Println("OK")
+			}
+		},
+	}
+)
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func init() {
+
+	putCalendarCommand.Flags().StringVar(&calendarName, "name", "", "name of calendar.")
+	putCalendarCommand.Flags().StringVar(&calendarTimezone, "calendar-timezone", "", "time zone of the excluded dates (default: UTC).")
+	putCalendarCommand.Flags().StringSliceVar(&calendarDates, "dates", nil, "excluded dates, of the form YYYY-MM-DD.")
+	putCalendarCommand.Flags().StringSliceVar(&calendarRanges, "ranges", nil, "excluded ranges, of the form <RFC 3339 start>/<RFC 3339 end>.")
+
+	viper.BindPFlag("name", putCalendarCommand.Flags().Lookup("name"))
+	viper.BindPFlag("calendar-timezone", putCalendarCommand.Flags().Lookup("calendar-timezone"))
+	viper.BindPFlag("dates", putCalendarCommand.Flags().Lookup("dates"))
+	viper.BindPFlag("ranges", putCalendarCommand.Flags().Lookup("ranges"))
+
+	rootCmd.AddCommand(putCalendarCommand)
+}
+
+var (
+	calendarName     string
+	calendarTimezone string
+	calendarDates    []string
+	calendarRanges   []string
+
+	putCalendarCommand = &cobra.Command{
+		Use:   "put-calendar",
+		Short: "Creates or replaces a calendar.",
+		Long:  "Creates or replaces a calendar of excluded dates and ranges, timers created afterwards can refer to it by name.",
+		Run: func(cmd *cobra.Command, args []string) {
+			calendar := common.Calendar{
+				Name:     calendarName,
+				Timezone: calendarTimezone,
+				Dates:    calendarDates,
+			}
+
+			for _, r := range calendarRanges {
+				bounds := strings.SplitN(r, "/", 2)
+				if len(bounds) != 2 {
+					fmt.Println("invalid range:", r)
+					return
+				}
+
+				start, err := time.Parse(time.RFC3339, bounds[0])
+				if err != nil {
+					fmt.Println("invalid range start:", err)
+					return
+				}
+
+				end, err := time.Parse(time.RFC3339, bounds[1])
+				if err != nil {
+					fmt.Println("invalid range end:", err)
+					return
+				}
+
+				calendar.Ranges = append(calendar.Ranges, &common.TimeRange{
+					Start: timestamppb.New(start),
+					End:   timestamppb.New(end),
+				})
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			_, err := client.PutCalendar(ctx, &services.PutCalendarRequest{Calendar: &calendar})
+			if err != nil {
+				fmt.Println("err:", err)
+			} else {
+				fmt.Println("OK")
+			}
+		},
+	}
+)
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	triggerCommand.Flags().StringVar(&id, "id", "", "id of timer.")
+
+	rootCmd.AddCommand(triggerCommand)
+}
+
+var triggerCommand = &cobra.Command{
+	Use:   "trigger",
+	Short: "Triggers a timer.",
+	Long:  "Executes a timer's task once now, without changing its schedule.",
+	Run: func(cmd *cobra.Command, args []string) {
+		req := services.TriggerTimerRequest{
+			TimerUuid: id,
+		}
+
+		ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+		res, err := client.TriggerTimer(ctx, &req)
+		if err != nil {
+			fmt.Println("err:", err)
+		} else {
+			fmt.Println("trigger id:", res.TriggerId)
+		}
+	},
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func init() {
+	updateHTTPCommand.Flags().StringVar(&id, "id", "", "id of timer.")
+	updateHTTPCommand.Flags().StringVar(&updateCron, "cron", "", "cron schedule for timer.")
+	updateHTTPCommand.Flags().IntVar(&updateMaxExecutions, "max-executions", 0, "max executions of timer, zero means infinite executions.")
+	updateHTTPCommand.Flags().StringVar(&updateURL, "url", "", "url endpoint you want to hit.")
+	updateHTTPCommand.Flags().StringVar(&updateMethod, "method", "GET", "http method, one of GET, POST, PUT, PATCH, DELETE, HEAD or OPTIONS.")
+	updateHTTPCommand.Flags().StringVar(&updateBody, "body", "", "body of the http request.")
+	updateHTTPCommand.Flags().StringToStringVar(&updateLabels, "labels", nil, "labels of the timer, replacing all of its labels.")
+	updateHTTPCommand.Flags().BoolVar(&resetProgress, "reset-progress", false, "whether the timer starts over rather than keeping its progress.")
+
+	rootCmd.AddCommand(updateHTTPCommand)
+}
+
+var (
+	updateCron          string
+	updateMaxExecutions int
+	updateURL           string
+	updateMethod        string
+	updateBody          string
+	updateLabels        map[string]string
+	resetProgress       bool
+
+	// updateHTTPPaths are the fields of the request set by each flag.
+	updateHTTPPaths = map[string]string{
+		"cron":           "schedule.cron",
+		"max-executions": "schedule.max_executions",
+		"url":            "task.http.url",
+		"method":         "task.http.method",
+		"body":           "task.http.body",
+		"labels":         "labels",
+	}
+
+	updateHTTPCommand = &cobra.Command{
+		Use:   "update-http",
+		Short: "Updates an http timer.",
+		Long:  "Updates the fields of an http timer that are given as flags.",
+		Run: func(cmd *cobra.Command, args []string) {
+			m, ok := common.Method_value[strings.ToUpper(updateMethod)]
+			if !ok {
+				fmt.Println("unknown method:", updateMethod)
+				return
+			}
+
+			var b []byte
+			if updateBody != "" {
+				b = []byte(updateBody)
+			}
+
+			req := services.UpdateTimerRequest{
+				TimerUuid: id,
+				Task: &common.Task{
+					Task: &common.Task_Http{
+						Http: &common.HTTP{
+							Url:    updateURL,
+							Method: common.Method(m),
+							Body:   b,
+						},
+					},
+				},
+				Schedule: &common.Schedule{
+					Spec:          &common.Schedule_Cron{Cron: updateCron},
+					MaxExecutions: int32(updateMaxExecutions),
+				},
+				Labels:        updateLabels,
+				UpdateMask:    &fieldmaskpb.FieldMask{},
+				ResetProgress: resetProgress,
+			}
+
+			for flag, path := range updateHTTPPaths {
+				if cmd.Flags().Changed(flag) {
+					req.UpdateMask.Paths = append(req.UpdateMask.Paths, path)
+				}
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			_, err := client.UpdateTimer(ctx, &req)
+			if err != nil {
+				fmt.Println("err:", err)
+			} else {
+				fmt.Println("OK")
+			}
+		},
+	}
+)
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func init() {
+	watchCommand.Flags().StringSliceVar(&ids, "ids", nil, "only executions of these timers.")
+	watchCommand.Flags().StringVar(&cursor, "cursor", "", "cursor of the last execution recieved, to resume after it.")
+
+	rootCmd.AddCommand(watchCommand)
+}
+
+var (
+	ids    []string
+	cursor string
+
+	watchCommand = &cobra.Command{
+		Use:   "watch",
+		Short: "Watches executions.",
+		Long:  "Prints executions as they're recorded, with the cursor to resume after each one.",
+		Run: func(cmd *cobra.Command, args []string) {
+			req := services.WatchExecutionsRequest{
+				TimerUuids: ids,
+				Cursor:     cursor,
+			}
+
+			ctx := basicAuthCtx(cmd.Context(), apiToken, apiSecret)
+			stream, err := client.WatchExecutions(ctx, &req)
+			if err != nil {
+				fmt.Println("err:", err)
+				return
+			}
+
+			for {
+				res, err := stream.Recv()
+				if err != nil {
+					fmt.Println("err:", err)
+					return
+				}
+
+				fmt.Println(protojson.Format(res.Execution))
+				fmt.Println("cursor:", res.Cursor)
+			}
+		},
+	}
+)
@@ -5,6 +5,14 @@ import (
 	"time"
 )
 
+// Outcomes of an execution record.
+const (
+	OutcomeUnknown = "unknown"
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeSkipped = "skipped"
+)
+
 type (
 	// Index is a request to index a new document.
 	Index struct {
@@ -13,27 +21,85 @@ type (
 
 	// Get is the response to a get request.
 	Get struct {
-		Found  bool `json:"found"`
-		Source struct {
+		Found       bool `json:"found"`
+		SeqNo       int  `json:"_seq_no"` // with PrimaryTerm, the version of the document for optimistic concurrency.
+		PrimaryTerm int  `json:"_primary_term"`
+		Source      struct {
 			Doc json.RawMessage `json:"doc"`
 		} `json:"_source"`
 	}
 
+	// Hit is a document in a search or multi get response.
+	Hit struct {
+		ID     string          `json:"_id"`
+		Found  bool            `json:"found"`
+		Source json.RawMessage `json:"_source"`
+		Sort   json.RawMessage `json:"sort"` // the sort values of a search hit, for search_after.
+	}
+
+	// Search is the response to a search request.
+	Search struct {
+		ScrollID string `json:"_scroll_id"` // set if the search was scrolled.
+		Hits     struct {
+			Hits []Hit `json:"hits"`
+		} `json:"hits"`
+	}
+
+	// Mget is the response to a multi get request.
+	Mget struct {
+		Docs []Hit `json:"docs"`
+	}
+
 	// User is a user document.
 	User struct {
 		HashedAPIKey string `json:"hashed_api_key"`
 	}
 
+	// Idempotency is the record of a CreateTimer request with an idempotency key.
+	Idempotency struct {
+		RequestHash string    `json:"request_hash"`
+		TimerUUID   string    `json:"timer_uuid"`
+		Create      []byte    `json:"create"` // the marshalled messaging.Create.
+		Published   bool      `json:"published"`
+		CreateTime  time.Time `json:"create_time"` // the key expires a while after, records from before it was recorded are expired.
+	}
+
+	// Registration is the frontend's record of a timer, its id is the timer uuid.
+	Registration struct {
+		Create    []byte   `json:"create"` // the marshalled messaging.Create last published.
+		Deleted   bool     `json:"deleted"`
+		Calendars []string `json:"calendars,omitempty"` // the names of the calendars the schedule refers to.
+	}
+
+	// Bulk is the response to a bulk request.
+	Bulk struct {
+		Items []map[string]BulkItem `json:"items"` // by the action of the item.
+	}
+
+	// BulkItem is the result of an action in a bulk request.
+	BulkItem struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	}
+
 	// ExecuteTimer is an execution record.
 	ExecuteTimer struct {
 		TimerUUID      string          `json:"timer_uuid"`
 		KafkaTimestamp time.Time       `json:"kafka_timestamp"`
 		Result         json.RawMessage `json:"result"`
+		Outcome        string          `json:"outcome"`
+		Manual         bool            `json:"manual"`
+		TriggerID      string          `json:"trigger_id,omitempty"`
+		Duration       time.Duration   `json:"duration_ns"`
 	}
 
-	// Progress is the value of a timers progress.
+	// Progress is the value of a timers progress, its id is the timer uuid.
 	Progress struct {
-		LastExecution       time.Time `json:"last_execution"`
-		CompletedExecutions int       `json:"result"`
+		Domain              string     `json:"domain"`
+		Partition           int32      `json:"partition"`      // runtimers load the progresses of their partitions.
+		LastExecution       *time.Time `json:"last_execution"` // null if unset, progress indexed before was the unix epoch.
+		LastScheduled       *time.Time `json:"last_scheduled"`
+		CompletedExecutions int        `json:"result"`
 	}
 )
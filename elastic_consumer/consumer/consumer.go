@@ -6,6 +6,7 @@ import (
 	"github.com/Shopify/sarama"
 	"github.com/nivista/steady/elastic_consumer/db"
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -52,16 +53,36 @@ func (c *consumer) consumeCreateClaim(session sarama.ConsumerGroupSession, claim
 			continue
 		}
 
-		var key messaging.Key
-		err := proto.Unmarshal(msg.Key, &key)
+		key, err := keys.Decode(msg.Key)
 		if err != nil {
-			fmt.Println("error unmarshalling keys")
+			fmt.Println("consumeCreateClaim decode key:", err.Error())
 			session.MarkMessage(msg, "")
 			continue
 		}
 
+		if key.Kind == messaging.KeyKind_KEY_KIND_STATE && msg.Value != nil {
+			var state messaging.State
+			err = proto.Unmarshal(msg.Value, &state)
+			if err != nil {
+				fmt.Println("consumeCreateClaim unmarshal state:", err.Error())
+			} else if err = c.db.SetTimerState(session.Context(), key.Domain, key.TimerUUID, &state); err != nil {
+				fmt.Println("consumeCreateClaim set state err:", err.Error())
+			}
+			session.MarkMessage(msg, "")
+			continue
+		}
+
+		if key.Kind != messaging.KeyKind_KEY_KIND_CREATE {
+			// triggers aren't indexed, and state tombstones come with the timers deletion.
+			session.MarkMessage(msg, "")
+			continue
+		}
+
+		// deleted and finished timers are tombstoned, they're removed so they aren't listed or updated.
 		if msg.Value == nil {
-			//todo delete, done event
+			if err = c.db.DeleteTimer(session.Context(), key.Domain, key.TimerUUID); err != nil {
+				fmt.Println("consumeCreateClaim delete err:", err.Error())
+			}
 			session.MarkMessage(msg, "")
 			continue
 		}
@@ -91,10 +112,9 @@ func (c *consumer) consumeExecuteClaim(session sarama.ConsumerGroupSession, clai
 			continue
 		}
 
-		var key messaging.Key
-		err := proto.Unmarshal(msg.Key, &key)
+		key, err := keys.Decode(msg.Key)
 		if err != nil {
-			fmt.Println("consumeExecuteClaim unmarshalling keys")
+			fmt.Println("consumeExecuteClaim decode key:", err.Error())
 			session.MarkMessage(msg, "")
 			continue
 		}
@@ -8,12 +8,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"strings"
 	"time"
 
 	elasticsearch "github.com/elastic/go-elasticsearch"
 	"github.com/elastic/go-elasticsearch/esapi"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/nivista/steady/elastic"
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
@@ -25,6 +27,9 @@ type (
 		ExecuteTimer(ctx context.Context, domain, id string, partition int32, kafkaTimestamp time.Time, value *messaging.Execute) error
 		CreateTimer(ctx context.Context, domain, id string, value *messaging.Create) error
 		DeleteTimer(ctx context.Context, domain, id string) error
+		SetTimerState(ctx context.Context, domain, id string, value *messaging.State) error
+		// PutTemplates puts the index templates that map the fields the webservice sorts and filters on.
+		PutTemplates(ctx context.Context) error
 	}
 
 	client struct {
@@ -44,15 +49,89 @@ func NewClient(elastic *elasticsearch.Client, executionsIndex, progressIndex, ti
 	}
 }
 
+// keywordField is a keyword with a keyword subfield, so fields can be queried by their keyword subfield in
+// indices created before the templates, where dynamic mapping made them text with a keyword subfield.
+var keywordField = map[string]interface{}{
+	"type":   "keyword",
+	"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword"}},
+}
+
+func (c *client) PutTemplates(ctx context.Context) error {
+	templates := map[string]interface{}{
+		c.timerIndex: map[string]interface{}{
+			"index_patterns": []string{c.timerIndex + "-*"},
+			"mappings": map[string]interface{}{
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"labels": map[string]interface{}{
+							"path_match": "labels.*",
+							"mapping":    keywordField,
+						},
+					},
+				},
+				"properties": map[string]interface{}{
+					"meta": map[string]interface{}{
+						"properties": map[string]interface{}{"timerUuid": keywordField},
+					},
+					"paused": map[string]interface{}{"type": "boolean"},
+				},
+			},
+		},
+		c.execIndex: map[string]interface{}{
+			"index_patterns": []string{c.execIndex + "-*"},
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"timer_uuid":      keywordField,
+					"kafka_timestamp": map[string]interface{}{"type": "date"},
+					"result":          map[string]interface{}{"type": "object", "enabled": false},
+					"duration_ns":     map[string]interface{}{"type": "long"},
+					"outcome":         keywordField,
+					"manual":          map[string]interface{}{"type": "boolean"},
+					"trigger_id":      keywordField,
+				},
+			},
+		},
+	}
+
+	for name, template := range templates {
+		body, err := json.Marshal(template)
+		if err != nil {
+			return err
+		}
+
+		req := esapi.IndicesPutTemplateRequest{
+			Name: name,
+			Body: bytes.NewReader(body),
+		}
+		res, err := req.Do(ctx, c.elastic)
+		if err != nil {
+			return err
+		}
+		if res.IsError() {
+			err = fmt.Errorf("putting template %v: %v", name, res.String())
+		}
+		res.Body.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *client) ExecuteTimer(ctx context.Context, domain, id string, partition int32, kafkaTimestamp time.Time, value *messaging.Execute) error {
 	// write execution
 	// index : c.execIndex + "-" + value.Domain
-	// _id   : value.TimerUUID + kafkaTimestamp
+	// _id   : value.TimerUUID + kafkaTimestamp, + value.TriggerId for manual executions
 
 	executeTimer := elastic.ExecuteTimer{
 		TimerUUID:      id,
 		KafkaTimestamp: kafkaTimestamp,
 		Result:         value.Result,
+		Outcome:        getOutcome(value.Outcome),
+		Manual:         value.Manual,
+		TriggerID:      value.TriggerId,
+		Duration:       value.Duration.AsDuration(),
 	}
 
 	doc, err := json.Marshal(executeTimer)
@@ -60,20 +139,33 @@ func (c *client) ExecuteTimer(ctx context.Context, domain, id string, partition
 		return fmt.Errorf("marshalling executeTimer: %v", err)
 	}
 
-	res, err := c.elastic.Create(strings.Join([]string{c.execIndex, domain}, "-"),
-		strings.Join([]string{id, kafkaTimestamp.String()}, "-"), bytes.NewReader(doc))
+	// a manual and a scheduled execution can have the same timestamp, the trigger keeps them from colliding.
+	docID := strings.Join([]string{id, kafkaTimestamp.String()}, "-")
+	if value.TriggerId != "" {
+		docID = strings.Join([]string{docID, value.TriggerId}, "-")
+	}
+
+	res, err := c.elastic.Create(strings.Join([]string{c.execIndex, domain}, "-"), docID, bytes.NewReader(doc))
 	if err != nil {
 		return errors.New("error creating execution record: " + err.Error())
 	}
 	defer res.Body.Close()
 	io.Copy(ioutil.Discard, res.Body)
 
+	// manual executions don't affect progress
+	if value.Manual {
+		return nil
+	}
+
 	// write progress
 	// index : x.progIndex
 	// _id : value.TimerUUID
 
 	progress := elastic.Progress{
-		LastExecution:       value.Progress.LastExecution.AsTime(),
+		Domain:              domain,
+		Partition:           partition,
+		LastExecution:       getTime(value.Progress.LastExecution),
+		LastScheduled:       getTime(value.Progress.LastScheduled),
 		CompletedExecutions: int(value.Progress.CompletedExecutions),
 	}
 	doc, err = json.Marshal(progress)
@@ -98,28 +190,97 @@ func (c *client) ExecuteTimer(ctx context.Context, domain, id string, partition
 	return nil
 }
 
+// getTime returns nil for unset timestamps, so they're indexed as null.
+func getTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func getOutcome(outcome messaging.Outcome) string {
+	switch outcome {
+	case messaging.Outcome_OUTCOME_SUCCESS:
+		return elastic.OutcomeSuccess
+	case messaging.Outcome_OUTCOME_FAILURE:
+		return elastic.OutcomeFailure
+	case messaging.Outcome_OUTCOME_SKIPPED:
+		return elastic.OutcomeSkipped
+	default:
+		return elastic.OutcomeUnknown
+	}
+}
+
+// timerScript replaces a timer, keeping whether it's paused.
+const timerScript = `boolean paused = ctx._source.paused == true; ctx._source.clear(); ctx._source.putAll(params.timer); ctx._source.paused = paused;`
+
 func (c *client) CreateTimer(ctx context.Context, domain, id string, value *messaging.Create) error {
 	// write timer
 	// index : c.timerIndex + "-" + value.Domain
 	// id    : value.TimerUUID
 
-	json, err := marshaller.Marshal(value)
+	timer, err := marshaller.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.elastic.Create(strings.Join([]string{c.timerIndex, domain}, "-"), id, bytes.NewReader(json))
+	// scripted rather than indexed, so updates replace the timer without resetting its state.
+	doc, err := json.Marshal(map[string]interface{}{
+		"scripted_upsert": true,
+		"script": map[string]interface{}{
+			"source": timerScript,
+			"params": map[string]interface{}{"timer": json.RawMessage(timer)},
+		},
+		"upsert": map[string]interface{}{},
+	})
 	if err != nil {
 		return err
 	}
 
+	res, err := c.elastic.Update(strings.Join([]string{c.timerIndex, domain}, "-"), id, bytes.NewReader(doc))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("updating timer: %v", res.String())
+	}
 	return nil
 }
 
+// SetTimerState records whether a timer is paused, timers that don't exist are ignored.
+func (c *client) SetTimerState(ctx context.Context, domain, id string, value *messaging.State) error {
+	doc, err := json.Marshal(map[string]interface{}{
+		"doc": map[string]interface{}{"paused": value.Paused},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := c.elastic.Update(strings.Join([]string{c.timerIndex, domain}, "-"), id, bytes.NewReader(doc))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("updating timer state: %v", res.String())
+	}
+	return nil
+}
+
+// DeleteTimer removes a timer, timers that don't exist are ignored.
 func (c *client) DeleteTimer(ctx context.Context, domain, id string) error {
-	_, err := c.elastic.Delete(strings.Join([]string{c.timerIndex, domain}, "-"), id)
+	res, err := c.elastic.Delete(strings.Join([]string{c.timerIndex, domain}, "-"), id)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("deleting timer: %v", res.String())
+	}
 	return nil
 }
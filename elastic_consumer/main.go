@@ -66,6 +66,10 @@ func main() {
 		panic(err)
 	}
 	db := db.NewClient(elasticClient, viper.GetString(elasticExecutionsIndex), viper.GetString(elasticProgressIndex), viper.GetString(elasticTimersIndex))
+	// before consuming, so indices created for new domains are mapped.
+	if err = db.PutTemplates(context.Background()); err != nil {
+		panic(err)
+	}
 
 	// get Kafka Version
 	version, err := sarama.ParseKafkaVersion(viper.GetString("KAFKA_VERSION"))
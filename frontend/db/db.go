@@ -5,12 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/nivista/steady/.gen/protos/common"
 	"github.com/nivista/steady/elastic"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
 	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type (
@@ -18,6 +27,48 @@ type (
 	Client interface {
 		UpsertUser(ctx context.Context, apiToken, apiKey string) error
 		AuthenticateUser(ctx context.Context, apiToken, apiSecret string) error
+		PutCalendar(ctx context.Context, domain string, calendar *common.Calendar) error
+		GetCalendar(ctx context.Context, domain, name string) (*common.Calendar, error)
+		DeleteCalendar(ctx context.Context, domain, name string) error
+		GetTimer(ctx context.Context, domain, id string) (*Timer, error)
+		GetTimerIDs(ctx context.Context, domain string, ids []string) (map[string]bool, error)
+		RegisterTimers(ctx context.Context, domain string, creates []*messaging.Create) []error
+		GetRegisteredTimer(ctx context.Context, domain, id string) (*RegisteredTimer, error)
+		GetRegisteredTimerIDs(ctx context.Context, domain string, ids []string) (map[string]bool, error)
+		GetCalendarTimerIDs(ctx context.Context, domain, name string) ([]string, error)
+		UpdateRegisteredTimer(ctx context.Context, domain string, t *RegisteredTimer, create *messaging.Create) error
+		UnregisterTimers(ctx context.Context, domain string, ids []string) []error
+		ListTimers(ctx context.Context, domain string, filter TimerFilter, size int, after string) ([]*Timer, error)
+		GetProgresses(ctx context.Context, ids []string) (map[string]*messaging.Progress, error)
+		ReserveIdempotencyKey(ctx context.Context, domain, key string, record *elastic.Idempotency) (*elastic.Idempotency, error)
+		DeleteExpiredIdempotencyKeys(ctx context.Context) error
+		PublishedIdempotencyKey(ctx context.Context, domain, key string) error
+		ListExecutions(ctx context.Context, domain string, filter ExecutionFilter, size int, after json.RawMessage) ([]*elastic.ExecuteTimer, json.RawMessage, error)
+	}
+
+	// Timer is an indexed timer.
+	Timer struct {
+		Create *messaging.Create
+		Paused bool
+	}
+
+	// RegisteredTimer is a timer as it was last published.
+	RegisteredTimer struct {
+		Create             *messaging.Create
+		SeqNo, PrimaryTerm int // the version of the registration, it's only updated if it's unchanged.
+	}
+
+	// TimerFilter selects the timers to list.
+	TimerFilter struct {
+		Paused *bool // nil for every timer.
+		Labels map[string]string
+	}
+
+	// ExecutionFilter selects the executions to list, zero fields select every execution.
+	ExecutionFilter struct {
+		TimerUUID  string
+		Start, End time.Time
+		Outcome    string
 	}
 
 	// InvalidAPIToken is the error returned when provided with an invalid APIToken
@@ -27,21 +78,41 @@ type (
 	InvalidAPISecret error
 
 	client struct {
-		elastic    *elasticsearch.Client
-		usersIndex string
+		elastic                                                                                                  *elasticsearch.Client
+		usersIndex, calendarsIndex, timersIndex, progressIndex, executionsIndex, idempotencyIndex, registryIndex string
+		idempotencyTTL                                                                                           time.Duration
 	}
 )
 
 var (
 	errInvalidAPIToken  InvalidAPIToken  = errors.New("invalid api token")
 	errInvalidAPISecret InvalidAPISecret = errors.New("invalid api secret")
+
+	// ErrCalendarNotFound is returned when a domain doesn't have a calendar with the given name.
+	ErrCalendarNotFound = errors.New("calendar not found")
+
+	// ErrTimerNotFound is returned when a domain doesn't have a timer with the given id.
+	ErrTimerNotFound = errors.New("timer not found")
+
+	// ErrTimerNotRegistered is returned when the registry has no record of a timer, timers created before it was added aren't registered.
+	ErrTimerNotRegistered = errors.New("timer not registered")
+
+	// ErrRegistrationConflict is returned when a registered timer changed since it was read.
+	ErrRegistrationConflict = errors.New("registration changed since it was read")
 )
 
 // NewClient returns a new client to the database.
-func NewClient(elastic *elasticsearch.Client, usersIndex string) Client {
+func NewClient(elastic *elasticsearch.Client, usersIndex, calendarsIndex, timersIndex, progressIndex, executionsIndex, idempotencyIndex, registryIndex string, idempotencyTTL time.Duration) Client {
 	return &client{
-		elastic:    elastic,
-		usersIndex: usersIndex,
+		elastic:          elastic,
+		usersIndex:       usersIndex,
+		calendarsIndex:   calendarsIndex,
+		timersIndex:      timersIndex,
+		progressIndex:    progressIndex,
+		executionsIndex:  executionsIndex,
+		idempotencyIndex: idempotencyIndex,
+		registryIndex:    registryIndex,
+		idempotencyTTL:   idempotencyTTL,
 	}
 }
 
@@ -100,3 +171,1007 @@ func (c *client) AuthenticateUser(ctx context.Context, apiToken, apiSecret strin
 
 	return nil
 }
+
+// PutCalendar creates a domain's calendar, or replaces the calendar with the same name.
+func (c *client) PutCalendar(ctx context.Context, domain string, calendar *common.Calendar) error {
+	doc, err := protojson.Marshal(calendar)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(elastic.Index{
+		Doc: json.RawMessage(doc),
+	})
+	if err != nil {
+		return err
+	}
+
+	indexRequest := esapi.IndexRequest{
+		Index:      c.getCalendarsIndex(domain),
+		DocumentID: calendar.Name,
+		Body:       bytes.NewReader(data),
+	}
+	res, err := indexRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("indexing calendar: %v", res.String())
+	}
+	return nil
+}
+
+// GetCalendar returns ErrCalendarNotFound if the domain doesn't have the calendar.
+func (c *client) GetCalendar(ctx context.Context, domain, name string) (*common.Calendar, error) {
+	getRequest := esapi.GetRequest{
+		Index:      c.getCalendarsIndex(domain),
+		DocumentID: name,
+	}
+	res, err := getRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound { // the calendar, or the domain's index, doesn't exist.
+		return nil, ErrCalendarNotFound
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("getting calendar: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var get elastic.Get
+	err = json.Unmarshal(data, &get)
+	if err != nil {
+		return nil, err
+	}
+
+	if !get.Found {
+		return nil, ErrCalendarNotFound
+	}
+
+	var calendar common.Calendar
+	if err = protojson.Unmarshal(get.Source.Doc, &calendar); err != nil {
+		return nil, err
+	}
+
+	return &calendar, nil
+}
+
+// DeleteCalendar returns ErrCalendarNotFound if the domain doesn't have the calendar.
+func (c *client) DeleteCalendar(ctx context.Context, domain, name string) error {
+	deleteRequest := esapi.DeleteRequest{
+		Index:      c.getCalendarsIndex(domain),
+		DocumentID: name,
+	}
+	res, err := deleteRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrCalendarNotFound
+	}
+
+	if res.IsError() {
+		return fmt.Errorf("deleting calendar: %v", res.String())
+	}
+	return nil
+}
+
+// GetTimer returns ErrTimerNotFound if the domain doesn't have the timer.
+// Timers are indexed by the elastic consumer, so recently created timers may not be found.
+func (c *client) GetTimer(ctx context.Context, domain, id string) (*Timer, error) {
+	getRequest := esapi.GetRequest{
+		Index:      c.getTimersIndex(domain),
+		DocumentID: id,
+	}
+	res, err := getRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrTimerNotFound
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("getting timer: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var get elastic.Hit
+	err = json.Unmarshal(data, &get)
+	if err != nil {
+		return nil, err
+	}
+
+	if !get.Found {
+		return nil, ErrTimerNotFound
+	}
+
+	return getTimer(get.Source)
+}
+
+// GetTimerIDs returns which of the ids are timers of the domain in the timers index.
+// Like GetTimer, recently created timers may not be found, so it's only used for timers created before the registry.
+func (c *client) GetTimerIDs(ctx context.Context, domain string, ids []string) (map[string]bool, error) {
+	found := make(map[string]bool, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	mgetRequest := esapi.MgetRequest{
+		Index:  c.getTimersIndex(domain),
+		Body:   bytes.NewReader(body),
+		Source: []string{"false"},
+	}
+	res, err := mgetRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound { // the domain's index doesn't exist.
+		return found, nil
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("getting timer ids: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var mget elastic.Mget
+	if err = json.Unmarshal(data, &mget); err != nil {
+		return nil, err
+	}
+
+	for _, doc := range mget.Docs {
+		if doc.Found {
+			found[doc.ID] = true
+		}
+	}
+
+	return found, nil
+}
+
+// RegisterTimers records that the creates of new timers are published, in the registry of the domain.
+// Unlike the timers index, which lags behind the create topic, the registry is written before publishing.
+// Timers that are already registered, including deleted timers, are left as they are.
+func (c *client) RegisterTimers(ctx context.Context, domain string, creates []*messaging.Create) []error {
+	var body bytes.Buffer
+	for _, create := range creates {
+		createBytes, err := proto.Marshal(create)
+		if err != nil {
+			return getErrors(len(creates), err)
+		}
+
+		registration := elastic.Registration{Create: createBytes, Calendars: create.Schedule.GetCalendars()}
+		if err = writeBulkAction(&body, "create", create.Meta.GetTimerUuid(), registration); err != nil {
+			return getErrors(len(creates), err)
+		}
+	}
+
+	items, err := c.bulk(ctx, c.getRegistryIndex(domain), len(creates), &body)
+	if err != nil {
+		return getErrors(len(creates), err)
+	}
+
+	errs := make([]error, len(creates))
+	for i, item := range items {
+		if item.Error != nil && item.Status != http.StatusConflict {
+			errs[i] = fmt.Errorf("registering timer: %s", item.Error)
+		}
+	}
+	return errs
+}
+
+// GetRegisteredTimer returns a timer of the domain from the registry.
+// ErrTimerNotFound is returned for deleted timers, ErrTimerNotRegistered for timers the registry has no record of.
+func (c *client) GetRegisteredTimer(ctx context.Context, domain, id string) (*RegisteredTimer, error) {
+	getRequest := esapi.GetRequest{
+		Index:      c.getRegistryIndex(domain),
+		DocumentID: id,
+	}
+	res, err := getRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrTimerNotRegistered
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("getting registered timer: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var get elastic.Get
+	if err = json.Unmarshal(data, &get); err != nil {
+		return nil, err
+	}
+
+	if !get.Found {
+		return nil, ErrTimerNotRegistered
+	}
+
+	var registration elastic.Registration
+	if err = json.Unmarshal(get.Source.Doc, &registration); err != nil {
+		return nil, err
+	}
+
+	if registration.Deleted {
+		return nil, ErrTimerNotFound
+	}
+
+	var create messaging.Create
+	if err = proto.Unmarshal(registration.Create, &create); err != nil {
+		return nil, err
+	}
+
+	return &RegisteredTimer{Create: &create, SeqNo: get.SeqNo, PrimaryTerm: get.PrimaryTerm}, nil
+}
+
+// GetRegisteredTimerIDs returns which of the ids the registry of the domain has a record of, true if the timer isn't deleted.
+func (c *client) GetRegisteredTimerIDs(ctx context.Context, domain string, ids []string) (map[string]bool, error) {
+	registered := make(map[string]bool, len(ids))
+	if len(ids) == 0 {
+		return registered, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	mgetRequest := esapi.MgetRequest{
+		Index:          c.getRegistryIndex(domain),
+		Body:           bytes.NewReader(body),
+		SourceIncludes: []string{"doc.deleted"},
+	}
+	res, err := mgetRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound { // the domain's registry doesn't exist.
+		return registered, nil
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("getting registered timer ids: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var mget elastic.Mget
+	if err = json.Unmarshal(data, &mget); err != nil {
+		return nil, err
+	}
+
+	for _, doc := range mget.Docs {
+		if !doc.Found {
+			continue
+		}
+
+		var source struct {
+			Doc elastic.Registration `json:"doc"`
+		}
+		if err = json.Unmarshal(doc.Source, &source); err != nil {
+			return nil, fmt.Errorf("registration %v: %w", doc.ID, err)
+		}
+		registered[doc.ID] = !source.Doc.Deleted
+	}
+
+	return registered, nil
+}
+
+// calendarTimersPageSize is how many timers GetCalendarTimerIDs gets at a time.
+const calendarTimersPageSize = 1000
+
+// GetCalendarTimerIDs returns the ids of the domain's timers whose schedule refers to the calendar, some may be deleted.
+// The registry has the timers that aren't indexed yet, and the timers index those registered before registrations had calendars.
+func (c *client) GetCalendarTimerIDs(ctx context.Context, domain, name string) ([]string, error) {
+	body, err := json.Marshal(getCalendarTimersQuery(name))
+	if err != nil {
+		return nil, err
+	}
+
+	ignoreUnavailable := true
+	searchRequest := esapi.SearchRequest{
+		Index:             []string{c.getRegistryIndex(domain), c.getTimersIndex(domain)},
+		Body:              bytes.NewReader(body),
+		Scroll:            time.Minute,
+		IgnoreUnavailable: &ignoreUnavailable,
+	}
+	res, err := searchRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+
+	var scrollID string
+	defer func() {
+		if scrollID != "" {
+			c.clearScroll(scrollID)
+		}
+	}()
+
+	found := map[string]bool{}
+	var ids []string
+	for {
+		search, err := readSearch(res)
+		if err != nil {
+			return nil, err
+		}
+		scrollID = search.ScrollID
+
+		for _, hit := range search.Hits.Hits {
+			if !found[hit.ID] {
+				found[hit.ID] = true
+				ids = append(ids, hit.ID)
+			}
+		}
+
+		if len(search.Hits.Hits) < calendarTimersPageSize {
+			return ids, nil
+		}
+
+		body, err = json.Marshal(map[string]interface{}{"scroll": "1m", "scroll_id": scrollID})
+		if err != nil {
+			return nil, err
+		}
+
+		scrollRequest := esapi.ScrollRequest{Body: bytes.NewReader(body)}
+		if res, err = scrollRequest.Do(ctx, c.elastic.Transport); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getCalendarTimersQuery returns the search of GetCalendarTimerIDs, which matches timers in the registry and in the timers index.
+func getCalendarTimersQuery(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"size":    calendarTimersPageSize,
+		"_source": false,
+		"sort":    []string{"_doc"},
+		"query": map[string]interface{}{"bool": map[string]interface{}{
+			"should": []interface{}{
+				map[string]interface{}{"term": map[string]interface{}{"doc.calendars.keyword": name}},
+				map[string]interface{}{"term": map[string]interface{}{"schedule.calendars.keyword": name}},
+			},
+		}},
+	}
+}
+
+// readSearch reads the response to a search or scroll request, and closes it.
+func readSearch(res *esapi.Response) (*elastic.Search, error) {
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("searching: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var search elastic.Search
+	if err = json.Unmarshal(data, &search); err != nil {
+		return nil, err
+	}
+	return &search, nil
+}
+
+// clearScroll frees the search context of a scroll, it expires on its own if that fails.
+func (c *client) clearScroll(scrollID string) {
+	clearScrollRequest := esapi.ClearScrollRequest{ScrollID: []string{scrollID}}
+	res, err := clearScrollRequest.Do(context.Background(), c.elastic.Transport)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+}
+
+// UpdateRegisteredTimer records that the create of an update to t is published.
+// ErrRegistrationConflict is returned if t changed since it was read.
+func (c *client) UpdateRegisteredTimer(ctx context.Context, domain string, t *RegisteredTimer, create *messaging.Create) error {
+	createBytes, err := proto.Marshal(create)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(elastic.Index{Doc: elastic.Registration{Create: createBytes, Calendars: create.Schedule.GetCalendars()}})
+	if err != nil {
+		return err
+	}
+
+	indexRequest := esapi.IndexRequest{
+		Index:         c.getRegistryIndex(domain),
+		DocumentID:    create.Meta.GetTimerUuid(),
+		Body:          bytes.NewReader(data),
+		IfSeqNo:       &t.SeqNo,
+		IfPrimaryTerm: &t.PrimaryTerm,
+	}
+	res, err := indexRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		return ErrRegistrationConflict
+	}
+
+	if res.IsError() {
+		return fmt.Errorf("updating registered timer: %v", res.String())
+	}
+	return nil
+}
+
+// UnregisterTimers records that timers of the domain are deleted.
+// Their registrations are kept, so timers that are deleted aren't mistaken for timers created before the registry.
+func (c *client) UnregisterTimers(ctx context.Context, domain string, ids []string) []error {
+	var body bytes.Buffer
+	for _, id := range ids {
+		if err := writeBulkAction(&body, "index", id, elastic.Registration{Deleted: true}); err != nil {
+			return getErrors(len(ids), err)
+		}
+	}
+
+	items, err := c.bulk(ctx, c.getRegistryIndex(domain), len(ids), &body)
+	if err != nil {
+		return getErrors(len(ids), err)
+	}
+
+	errs := make([]error, len(ids))
+	for i, item := range items {
+		if item.Error != nil {
+			errs[i] = fmt.Errorf("unregistering timer: %s", item.Error)
+		}
+	}
+	return errs
+}
+
+// writeBulkAction writes an action on the document with the id to the body of a bulk request.
+func writeBulkAction(body *bytes.Buffer, action, id string, doc interface{}) error {
+	meta, err := json.Marshal(map[string]interface{}{action: map[string]interface{}{"_id": id}})
+	if err != nil {
+		return err
+	}
+
+	source, err := json.Marshal(elastic.Index{Doc: doc})
+	if err != nil {
+		return err
+	}
+
+	body.Write(meta)
+	body.WriteByte('\n')
+	body.Write(source)
+	body.WriteByte('\n')
+	return nil
+}
+
+// bulk does the n actions of body on the index, it returns the results of the actions in order.
+func (c *client) bulk(ctx context.Context, index string, n int, body *bytes.Buffer) ([]elastic.BulkItem, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	bulkRequest := esapi.BulkRequest{
+		Index: index,
+		Body:  body,
+	}
+	res, err := bulkRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("bulk request: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var bulk elastic.Bulk
+	if err = json.Unmarshal(data, &bulk); err != nil {
+		return nil, err
+	}
+
+	if len(bulk.Items) != n {
+		return nil, fmt.Errorf("bulk request: expected %v results, got %v", n, len(bulk.Items))
+	}
+
+	items := make([]elastic.BulkItem, 0, n)
+	for _, result := range bulk.Items {
+		for _, item := range result {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// getErrors returns err for each of n items.
+func getErrors(n int, err error) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// ListTimers returns at most size of the domain's timers that match filter, ordered by id and after the id after.
+func (c *client) ListTimers(ctx context.Context, domain string, filter TimerFilter, size int, after string) ([]*Timer, error) {
+	body, err := json.Marshal(getTimersQuery(filter, size, after))
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := esapi.SearchRequest{
+		Index: []string{c.getTimersIndex(domain)},
+		Body:  bytes.NewReader(body),
+	}
+	res, err := searchRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound { // the domain's index doesn't exist.
+		return nil, nil
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("searching timers: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var search elastic.Search
+	if err = json.Unmarshal(data, &search); err != nil {
+		return nil, err
+	}
+
+	timers := make([]*Timer, 0, len(search.Hits.Hits))
+	for _, hit := range search.Hits.Hits {
+		timer, err := getTimer(hit.Source)
+		if err != nil {
+			return nil, fmt.Errorf("timer %v: %w", hit.ID, err)
+		}
+		timers = append(timers, timer)
+	}
+
+	return timers, nil
+}
+
+// GetProgresses returns the progress of the timers with the given ids, timers that haven't executed are left out.
+func (c *client) GetProgresses(ctx context.Context, ids []string) (map[string]*messaging.Progress, error) {
+	progresses := make(map[string]*messaging.Progress, len(ids))
+	if len(ids) == 0 {
+		return progresses, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	mgetRequest := esapi.MgetRequest{
+		Index: c.progressIndex,
+		Body:  bytes.NewReader(body),
+	}
+	res, err := mgetRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("getting progresses: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var mget elastic.Mget
+	if err = json.Unmarshal(data, &mget); err != nil {
+		return nil, err
+	}
+
+	for _, doc := range mget.Docs {
+		if !doc.Found {
+			continue
+		}
+
+		var prog elastic.Progress
+		if err = json.Unmarshal(doc.Source, &prog); err != nil {
+			return nil, fmt.Errorf("progress %v: %w", doc.ID, err)
+		}
+
+		progresses[doc.ID] = &messaging.Progress{
+			CompletedExecutions: int32(prog.CompletedExecutions),
+			LastExecution:       getTimestamp(prog.LastExecution),
+			LastScheduled:       getTimestamp(prog.LastScheduled),
+		}
+	}
+
+	return progresses, nil
+}
+
+// ReserveIdempotencyKey records the request with a domain's idempotency key.
+// If the key is already reserved, the record it was reserved with is returned instead, unless it expired.
+func (c *client) ReserveIdempotencyKey(ctx context.Context, domain, key string, record *elastic.Idempotency) (*elastic.Idempotency, error) {
+	data, err := json.Marshal(elastic.Index{Doc: record})
+	if err != nil {
+		return nil, err
+	}
+
+	// created rather than indexed, so only the first request reserves the key.
+	createRequest := esapi.CreateRequest{
+		Index:      c.getIdempotencyIndex(domain),
+		DocumentID: key,
+		Body:       bytes.NewReader(data),
+	}
+	res, err := createRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if !res.IsError() {
+		return nil, nil
+	}
+
+	if res.StatusCode != http.StatusConflict {
+		return nil, fmt.Errorf("reserving idempotency key: %v", res.String())
+	}
+
+	existing, version, err := c.getIdempotencyKey(ctx, domain, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsIdempotencyKeyExpired(existing, time.Now(), c.idempotencyTTL) {
+		return existing, nil
+	}
+
+	// the expired key is reserved again, unless another request reserved it since it was read.
+	indexRequest := esapi.IndexRequest{
+		Index:         c.getIdempotencyIndex(domain),
+		DocumentID:    key,
+		Body:          bytes.NewReader(data),
+		IfSeqNo:       &version.SeqNo,
+		IfPrimaryTerm: &version.PrimaryTerm,
+	}
+	res, err = indexRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		existing, _, err = c.getIdempotencyKey(ctx, domain, key)
+		return existing, err
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("reserving expired idempotency key: %v", res.String())
+	}
+	return nil, nil
+}
+
+// getIdempotencyKey returns the record of a domain's idempotency key, and the version of its document.
+func (c *client) getIdempotencyKey(ctx context.Context, domain, key string) (*elastic.Idempotency, *elastic.Get, error) {
+	getRequest := esapi.GetRequest{
+		Index:      c.getIdempotencyIndex(domain),
+		DocumentID: key,
+	}
+	res, err := getRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, nil, fmt.Errorf("getting idempotency key: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var get elastic.Get
+	if err = json.Unmarshal(data, &get); err != nil {
+		return nil, nil, err
+	}
+
+	var existing elastic.Idempotency
+	if err = json.Unmarshal(get.Source.Doc, &existing); err != nil {
+		return nil, nil, err
+	}
+
+	return &existing, &get, nil
+}
+
+// IsIdempotencyKeyExpired returns whether the record of an idempotency key is older than ttl.
+func IsIdempotencyKeyExpired(record *elastic.Idempotency, now time.Time, ttl time.Duration) bool {
+	return record.CreateTime.Before(now.Add(-ttl))
+}
+
+// DeleteExpiredIdempotencyKeys deletes the records of idempotency keys of every domain that are older than the ttl.
+// Expired keys are reserved again without it, it keeps the records from accumulating.
+func (c *client) DeleteExpiredIdempotencyKeys(ctx context.Context) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					map[string]interface{}{"range": map[string]interface{}{
+						"doc.create_time": map[string]interface{}{"lt": time.Now().Add(-c.idempotencyTTL).Format(time.RFC3339Nano)},
+					}},
+					map[string]interface{}{"bool": map[string]interface{}{
+						"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "doc.create_time"}},
+					}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	deleteRequest := esapi.DeleteByQueryRequest{
+		Index:     []string{c.idempotencyIndex + "-*"},
+		Body:      bytes.NewReader(body),
+		Conflicts: "proceed",
+	}
+	res, err := deleteRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("deleting expired idempotency keys: %v", res.String())
+	}
+	return nil
+}
+
+// PublishedIdempotencyKey records that the timer of a domain's idempotency key was published.
+func (c *client) PublishedIdempotencyKey(ctx context.Context, domain, key string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"doc": map[string]interface{}{
+			"doc": map[string]interface{}{"published": true},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	updateRequest := esapi.UpdateRequest{
+		Index:      c.getIdempotencyIndex(domain),
+		DocumentID: key,
+		Body:       bytes.NewReader(data),
+	}
+	res, err := updateRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("updating idempotency key: %v", res.String())
+	}
+	return nil
+}
+
+func (c *client) getIdempotencyIndex(domain string) string {
+	return strings.Join([]string{c.idempotencyIndex, domain}, "-")
+}
+
+// ListExecutions returns at most size of the domain's executions that match filter, most recent first.
+// The sort values of the last execution are returned, executions after them are listed by passing them as after.
+func (c *client) ListExecutions(ctx context.Context, domain string, filter ExecutionFilter, size int, after json.RawMessage) ([]*elastic.ExecuteTimer, json.RawMessage, error) {
+	body, err := json.Marshal(getExecutionsQuery(filter, size, after))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	searchRequest := esapi.SearchRequest{
+		Index: []string{strings.Join([]string{c.executionsIndex, domain}, "-")},
+		Body:  bytes.NewReader(body),
+	}
+	res, err := searchRequest.Do(ctx, c.elastic.Transport)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound { // the domain's index doesn't exist.
+		return nil, nil, nil
+	}
+
+	if res.IsError() {
+		return nil, nil, fmt.Errorf("searching executions: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var search elastic.Search
+	if err = json.Unmarshal(data, &search); err != nil {
+		return nil, nil, err
+	}
+
+	var last json.RawMessage
+	executions := make([]*elastic.ExecuteTimer, 0, len(search.Hits.Hits))
+	for _, hit := range search.Hits.Hits {
+		var execution elastic.ExecuteTimer
+		if err = json.Unmarshal(hit.Source, &execution); err != nil {
+			return nil, nil, fmt.Errorf("execution %v: %w", hit.ID, err)
+		}
+		executions = append(executions, &execution)
+		last = hit.Sort
+	}
+
+	return executions, last, nil
+}
+
+// getExecutionsQuery returns the search of ListExecutions.
+// Like getTimersQuery, fields are queried by their keyword subfield.
+func getExecutionsQuery(filter ExecutionFilter, size int, after json.RawMessage) map[string]interface{} {
+	var must []interface{}
+	if filter.TimerUUID != "" {
+		must = append(must, map[string]interface{}{"term": map[string]interface{}{"timer_uuid.keyword": filter.TimerUUID}})
+	}
+
+	if filter.Outcome != "" {
+		must = append(must, map[string]interface{}{"term": map[string]interface{}{"outcome.keyword": filter.Outcome}})
+	}
+
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		timeRange := map[string]interface{}{}
+		if !filter.Start.IsZero() {
+			timeRange["gte"] = filter.Start.Format(time.RFC3339Nano)
+		}
+		if !filter.End.IsZero() {
+			timeRange["lt"] = filter.End.Format(time.RFC3339Nano)
+		}
+		must = append(must, map[string]interface{}{"range": map[string]interface{}{"kafka_timestamp": timeRange}})
+	}
+
+	query := map[string]interface{}{
+		"size":  size,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": must}},
+		"sort":  executionsSort,
+	}
+	if after != nil {
+		query["search_after"] = after
+	}
+	return query
+}
+
+// executionsSort orders executions from newest. A manual and a scheduled execution of a timer can have the same
+// kafka timestamp, so trigger_id, which scheduled executions don't have, makes the sort values unique for search_after.
+var executionsSort = []interface{}{
+	map[string]interface{}{"kafka_timestamp": "desc"},
+	map[string]interface{}{"timer_uuid.keyword": "asc"},
+	// indices without manual executions may not have the field mapped.
+	map[string]interface{}{"trigger_id.keyword": map[string]interface{}{"order": "asc", "missing": "", "unmapped_type": "keyword"}},
+}
+
+// IsExecutionsCursor returns whether after can be the sort values of the last execution returned by ListExecutions.
+func IsExecutionsCursor(after json.RawMessage) bool {
+	var values []json.RawMessage
+	return json.Unmarshal(after, &values) == nil && len(values) == len(executionsSort)
+}
+
+// getTimersQuery returns the search of ListTimers.
+// Fields are queried by their keyword subfield, which the timers template maps and dynamic mapping added before it.
+func getTimersQuery(filter TimerFilter, size int, after string) map[string]interface{} {
+	var must, mustNot []interface{}
+	if filter.Paused != nil {
+		// timers that were never paused don't have the field.
+		paused := map[string]interface{}{"term": map[string]interface{}{"paused": true}}
+		if *filter.Paused {
+			must = append(must, paused)
+		} else {
+			mustNot = append(mustNot, paused)
+		}
+	}
+
+	for key, value := range filter.Labels {
+		must = append(must, map[string]interface{}{"term": map[string]interface{}{"labels." + key + ".keyword": value}})
+	}
+
+	query := map[string]interface{}{
+		"size":  size,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": must, "must_not": mustNot}},
+		"sort":  []interface{}{map[string]interface{}{"meta.timerUuid.keyword": "asc"}},
+	}
+	if after != "" {
+		query["search_after"] = []string{after}
+	}
+	return query
+}
+
+// getTimer parses an indexed timer, the JSON encoded messaging.Create and whether it's paused.
+func getTimer(source []byte) (*Timer, error) {
+	var state struct {
+		Paused bool `json:"paused"`
+	}
+	if err := json.Unmarshal(source, &state); err != nil {
+		return nil, err
+	}
+
+	var create messaging.Create
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(source, &create); err != nil {
+		return nil, err
+	}
+
+	return &Timer{Create: &create, Paused: state.Paused}, nil
+}
+
+// getTimestamp returns nil for unset times, which are null, or the unix epoch in progress indexed before.
+func getTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || !t.After(time.Unix(0, 0)) {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func (c *client) getTimersIndex(domain string) string {
+	return strings.Join([]string{c.timersIndex, domain}, "-")
+}
+
+func (c *client) getRegistryIndex(domain string) string {
+	return strings.Join([]string{c.registryIndex, domain}, "-")
+}
+
+func (c *client) getCalendarsIndex(domain string) string {
+	return strings.Join([]string{c.calendarsIndex, domain}, "-")
+}
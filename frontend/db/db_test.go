@@ -0,0 +1,97 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nivista/steady/elastic"
+)
+
+func TestGetTimersQuery(t *testing.T) {
+	paused := true
+	query := getTimersQuery(TimerFilter{Paused: &paused, Labels: map[string]string{"team": "billing"}}, 10, "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51")
+
+	b, err := json.Marshal(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// uuids and labels are matched exactly, by their keyword subfield rather than analyzed text.
+	expect := `{"query":{"bool":{"filter":[{"term":{"paused":true}},{"term":{"labels.team.keyword":"billing"}}],"must_not":null}},` +
+		`"search_after":["8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"],"size":10,"sort":[{"meta.timerUuid.keyword":"asc"}]}`
+	if string(b) != expect {
+		t.Errorf("expected %v, got %v", expect, string(b))
+	}
+}
+
+func TestGetExecutionsQuery(t *testing.T) {
+	query := getExecutionsQuery(ExecutionFilter{TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Outcome: "failure"}, 10, json.RawMessage(`[1590000000000,"8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51",""]`))
+
+	b, err := json.Marshal(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := `{"query":{"bool":{"filter":[{"term":{"timer_uuid.keyword":"8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"}},{"term":{"outcome.keyword":"failure"}}]}},` +
+		`"search_after":[1590000000000,"8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51",""],"size":10,` +
+		`"sort":[{"kafka_timestamp":"desc"},{"timer_uuid.keyword":"asc"},{"trigger_id.keyword":{"missing":"","order":"asc","unmapped_type":"keyword"}}]}`
+	if string(b) != expect {
+		t.Errorf("expected %v, got %v", expect, string(b))
+	}
+}
+
+func TestIsExecutionsCursor(t *testing.T) {
+	var tests = []struct {
+		after json.RawMessage
+		valid bool
+	}{
+		{json.RawMessage(`[1590000000000,"8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51",""]`), true},
+		{json.RawMessage(`[1590000000000,"8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51","trigger"]`), true},
+		// cursors from before trigger_id was sorted on could skip or repeat executions.
+		{json.RawMessage(`[1590000000000,"8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"]`), false},
+		{json.RawMessage(`{"a":1}`), false},
+		{json.RawMessage(`[1,`), false},
+	}
+
+	for _, test := range tests {
+		if valid := IsExecutionsCursor(test.after); valid != test.valid {
+			t.Errorf("case: %s. expected valid %v, got %v", test.after, test.valid, valid)
+		}
+	}
+}
+
+func TestGetCalendarTimersQuery(t *testing.T) {
+	b, err := json.Marshal(getCalendarTimersQuery("holidays"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// registrations and indexed timers both match, by their keyword subfield.
+	expect := `{"_source":false,"query":{"bool":{"should":[{"term":{"doc.calendars.keyword":"holidays"}},{"term":{"schedule.calendars.keyword":"holidays"}}]}},` +
+		`"size":1000,"sort":["_doc"]}`
+	if string(b) != expect {
+		t.Errorf("expected %v, got %v", expect, string(b))
+	}
+}
+
+func TestIsIdempotencyKeyExpired(t *testing.T) {
+	now := time.Date(2020, 6, 2, 0, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name       string
+		createTime time.Time
+		expired    bool
+	}{
+		{"recent", now.Add(-time.Hour), false},
+		{"expired", now.Add(-25 * time.Hour), true},
+		// records from before create_time was recorded.
+		{"unknown", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		if expired := IsIdempotencyKeyExpired(&elastic.Idempotency{CreateTime: test.createTime}, now, 24*time.Hour); expired != test.expired {
+			t.Errorf("case: %v. expected expired %v, got %v", test.name, test.expired, expired)
+		}
+	}
+}
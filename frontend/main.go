@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/elastic/go-elasticsearch"
@@ -28,16 +30,23 @@ var (
 )
 
 const (
-	elasticURL             = "ELASTIC_URL"
-	elasticExecutionsIndex = "EXECUTIONS"
-	elasticTimersIndex     = "TIMERS"
-	elasticUsersIndex      = "USERS"
-	postgresURL            = "POSTGRES_URL"
-	createTopic            = "KAFKA_TOPIC"
-	partitions             = "PARTITIONS"
-	kafkaBrokers           = "KAFKA_BROKERS"
-	kafkaVersion           = "KAFKA_VERSION"
-	addr                   = "ADDR"
+	elasticURL              = "ELASTIC_URL"
+	elasticExecutionsIndex  = "EXECUTIONS"
+	elasticTimersIndex      = "TIMERS"
+	elasticUsersIndex       = "USERS"
+	elasticCalendarsIndex   = "CALENDARS"
+	elasticProgressIndex    = "PROGRESS"
+	elasticIdempotencyIndex = "IDEMPOTENCY"
+	elasticRegistryIndex    = "REGISTRY"
+	idempotencyTTL          = "IDEMPOTENCY_TTL"
+	postgresURL             = "POSTGRES_URL"
+	createTopic             = "KAFKA_TOPIC"
+	executeTopic            = "EXECUTE_KAFKA_TOPIC"
+	partitions              = "PARTITIONS"
+	kafkaBrokers            = "KAFKA_BROKERS"
+	kafkaVersion            = "KAFKA_VERSION"
+	addr                    = "ADDR"
+	signingKey              = "STEADY_SIGNING_KEY"
 )
 
 func init() {
@@ -45,12 +54,23 @@ func init() {
 	viper.SetDefault(elasticExecutionsIndex, "executions")
 	viper.SetDefault(elasticTimersIndex, "timers")
 	viper.SetDefault(elasticUsersIndex, "users")
+	viper.SetDefault(elasticCalendarsIndex, "calendars")
+	viper.SetDefault(elasticProgressIndex, "progress")
+	viper.SetDefault(elasticIdempotencyIndex, "idempotency")
+	viper.SetDefault(elasticRegistryIndex, "registry")
+	viper.SetDefault(idempotencyTTL, "24h")
 	viper.SetDefault(postgresURL, "postgresql://")
 	viper.SetDefault(createTopic, "create")
+	viper.SetDefault(executeTopic, "execute")
 	viper.SetDefault(partitions, 1)
 	viper.SetDefault(kafkaBrokers, "localhost:9092")
 	viper.SetDefault(kafkaVersion, "2.2.1")
 	viper.SetDefault(addr, "127.0.0.1:8080")
+	viper.SetDefault(signingKey, "")
+	// the key is a secret, so it can be set in the environment rather than the config file.
+	if err := viper.BindEnv(signingKey); err != nil {
+		panic(err)
+	}
 
 	if *configURL != "" {
 		viper.AddConfigPath(*configURL)
@@ -85,12 +105,18 @@ func main() {
 	}
 	queueClient := queue.NewClient(producer, viper.GetInt(partitions), viper.GetString(createTopic))
 
+	kafkaClient, err := sarama.NewClient(viper.GetStringSlice(kafkaBrokers), config)
+	if err != nil {
+		panic(err)
+	}
+	watcher := queue.NewWatcher(kafkaClient, viper.GetString(executeTopic))
+
 	// DB setup
 	elasticClient, err := elasticsearch.NewDefaultClient()
 	if err != nil {
 		panic(err)
 	}
-	dbClient := db.NewClient(elasticClient, viper.GetString(elasticUsersIndex))
+	dbClient := db.NewClient(elasticClient, viper.GetString(elasticUsersIndex), viper.GetString(elasticCalendarsIndex), viper.GetString(elasticTimersIndex), viper.GetString(elasticProgressIndex), viper.GetString(elasticExecutionsIndex), viper.GetString(elasticIdempotencyIndex), viper.GetString(elasticRegistryIndex), viper.GetDuration(idempotencyTTL))
 
 	l, err := net.Listen("tcp", viper.GetString(addr))
 	if err != nil {
@@ -104,8 +130,8 @@ func main() {
 	restListener := m.Match(cmux.Any())
 
 	// server for handling grpc requests
-	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(rpc.GetAuth(dbClient)))
-	steadyService := rpc.NewServer(queueClient)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(rpc.GetAuth(dbClient)), grpc.StreamInterceptor(rpc.GetStreamAuth(dbClient)))
+	steadyService := rpc.NewServer(queueClient, watcher, dbClient)
 	services.RegisterSteadyServer(grpcServer, steadyService)
 
 	// server for auth and elastic redirects
@@ -113,7 +139,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	restServer := rest.NewApp(dbClient, queueClient, viper.GetString(elasticTimersIndex), viper.GetString(elasticExecutionsIndex), elasticURL)
+	restServer := rest.NewApp(dbClient, queueClient, viper.GetString(elasticTimersIndex), viper.GetString(elasticExecutionsIndex), elasticURL, viper.GetString(signingKey))
 
 	go func() {
 		if err := grpcServer.Serve(grpcListener); err != nil {
@@ -133,6 +159,15 @@ func main() {
 		}
 	}()
 
+	// expired idempotency keys are already reserved again, they're deleted so they don't accumulate.
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := dbClient.DeleteExpiredIdempotencyKeys(context.Background()); err != nil {
+				log.Println("deleting expired idempotency keys:", err)
+			}
+		}
+	}()
+
 	// catch os signals
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
@@ -1,11 +1,13 @@
 package queue
 
 import (
+	"errors"
 	"hash/crc32"
 
 	"github.com/Shopify/sarama"
 	"github.com/google/uuid"
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -15,6 +17,12 @@ type (
 	Client interface {
 		PublishCreate(domain string, timerID uuid.UUID, timer *messaging.Create) error
 		PublishDelete(domain string, timerID uuid.UUID) error
+		PublishState(domain string, timerID uuid.UUID, state *messaging.State) error
+		PublishTrigger(domain string, timerID uuid.UUID, trigger *messaging.Trigger) error
+		// PublishCreates publishes the timers in one batch, and returns the error of each, in order.
+		PublishCreates(domain string, timers []*messaging.Create) []error
+		// PublishDeletes deletes the timers in one batch, and returns the error of each, in order.
+		PublishDeletes(domain string, timerIDs []uuid.UUID) []error
 	}
 
 	client struct {
@@ -39,11 +47,10 @@ func (c *client) PublishCreate(domain string, timerID uuid.UUID, timer *messagin
 		return err
 	}
 
-	key := messaging.Key{
+	keyBytes, err := keys.Encode(&messaging.Key{
 		Domain:    domain,
 		TimerUUID: timerID.String(),
-	}
-	keyBytes, err := proto.Marshal(&key)
+	})
 	if err != nil {
 		return err
 	}
@@ -58,12 +65,151 @@ func (c *client) PublishCreate(domain string, timerID uuid.UUID, timer *messagin
 	return err
 }
 
+// PublishDelete tombstones every kind of record of the timer.
 func (c *client) PublishDelete(domain string, timerID uuid.UUID) error {
+	msgs, err := c.deleteMessages(domain, timerID)
+	if err != nil {
+		return err
+	}
+
+	return c.producer.SendMessages(msgs)
+}
+
+func (c *client) PublishCreates(domain string, timers []*messaging.Create) []error {
+	errs := make([]error, len(timers))
+	var msgs []*sarama.ProducerMessage
+	for i, timer := range timers {
+		timerID, err := uuid.Parse(timer.Meta.TimerUuid)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		bytes, err := proto.Marshal(timer)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		keyBytes, err := keys.Encode(&messaging.Key{
+			Domain:    domain,
+			TimerUUID: timerID.String(),
+		})
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic:     c.topic,
+			Key:       sarama.ByteEncoder(keyBytes),
+			Value:     sarama.ByteEncoder(bytes),
+			Partition: c.bytesToPartition(timerID),
+			Metadata:  i,
+		})
+	}
+
+	c.sendBatch(msgs, errs)
+	return errs
+}
+
+func (c *client) PublishDeletes(domain string, timerIDs []uuid.UUID) []error {
+	errs := make([]error, len(timerIDs))
+	var msgs []*sarama.ProducerMessage
+	for i, timerID := range timerIDs {
+		timerMsgs, err := c.deleteMessages(domain, timerID)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		for _, msg := range timerMsgs {
+			msg.Metadata = i
+		}
+		msgs = append(msgs, timerMsgs...)
+	}
+
+	c.sendBatch(msgs, errs)
+	return errs
+}
+
+// deleteMessages returns the tombstones of every kind of record of the timer, in every key version.
+func (c *client) deleteMessages(domain string, timerID uuid.UUID) ([]*sarama.ProducerMessage, error) {
 	key := messaging.Key{
 		Domain:    domain,
 		TimerUUID: timerID.String(),
 	}
-	keyBytes, err := proto.Marshal(&key)
+
+	var msgs []*sarama.ProducerMessage
+	for _, kind := range []messaging.KeyKind{messaging.KeyKind_KEY_KIND_TRIGGER, messaging.KeyKind_KEY_KIND_STATE, messaging.KeyKind_KEY_KIND_CREATE} {
+		for _, version := range keys.Versions {
+			keyBytes, err := keys.WithKind(&key, kind, version)
+			if err != nil {
+				return nil, err
+			}
+
+			msgs = append(msgs, &sarama.ProducerMessage{
+				Topic:     c.topic,
+				Key:       sarama.ByteEncoder(keyBytes),
+				Value:     nil,
+				Partition: c.bytesToPartition(timerID),
+			})
+		}
+	}
+
+	return msgs, nil
+}
+
+// sendBatch sends msgs, whose Metadata is the index of their item, and sets the errors of the items that failed.
+func (c *client) sendBatch(msgs []*sarama.ProducerMessage, errs []error) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	err := c.producer.SendMessages(msgs)
+	if err == nil {
+		return
+	}
+
+	var producerErrs sarama.ProducerErrors
+	if !errors.As(err, &producerErrs) {
+		for _, msg := range msgs {
+			errs[msg.Metadata.(int)] = err
+		}
+		return
+	}
+
+	for _, producerErr := range producerErrs {
+		errs[producerErr.Msg.Metadata.(int)] = producerErr.Err
+	}
+}
+
+func (c *client) PublishState(domain string, timerID uuid.UUID, state *messaging.State) error {
+	return c.publish(timerID, &messaging.Key{
+		Domain:    domain,
+		TimerUUID: timerID.String(),
+		Kind:      messaging.KeyKind_KEY_KIND_STATE,
+	}, state)
+}
+
+func (c *client) PublishTrigger(domain string, timerID uuid.UUID, trigger *messaging.Trigger) error {
+	// keyed by the trigger, so triggers pending at the same time aren't compacted away.
+	return c.publish(timerID, &messaging.Key{
+		Domain:    domain,
+		TimerUUID: timerID.String(),
+		Kind:      messaging.KeyKind_KEY_KIND_TRIGGER,
+		TriggerId: trigger.TriggerId,
+	}, trigger)
+}
+
+// publish sends a record of a timer with the key.
+func (c *client) publish(timerID uuid.UUID, key *messaging.Key, value proto.Message) error {
+	bytes, err := proto.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	keyBytes, err := keys.Encode(key)
 	if err != nil {
 		return err
 	}
@@ -71,7 +217,7 @@ func (c *client) PublishDelete(domain string, timerID uuid.UUID) error {
 	_, _, err = c.producer.SendMessage(&sarama.ProducerMessage{
 		Topic:     c.topic,
 		Key:       sarama.ByteEncoder(keyBytes),
-		Value:     nil,
+		Value:     sarama.ByteEncoder(bytes),
 		Partition: c.bytesToPartition(timerID),
 	})
 
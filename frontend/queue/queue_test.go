@@ -0,0 +1,172 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/google/uuid"
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
+)
+
+var errProduce = errors.New("produce failed")
+
+// fakeProducer fails the messages of the items in fail, or every message if err is set.
+type fakeProducer struct {
+	sarama.SyncProducer
+	fail map[int]bool
+	err  error
+	sent []*sarama.ProducerMessage
+}
+
+func (p *fakeProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.sent = append(p.sent, msgs...)
+	if p.err != nil {
+		return p.err
+	}
+
+	var errs sarama.ProducerErrors
+	for _, msg := range msgs {
+		if p.fail[msg.Metadata.(int)] {
+			errs = append(errs, &sarama.ProducerError{Msg: msg, Err: errProduce})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// getErrs returns which items failed.
+func getErrs(t *testing.T, name string, errs []error, expect error) []bool {
+	failed := make([]bool, len(errs))
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, expect) {
+			t.Errorf("case: %v. expected error %v, got %v", name, expect, err)
+		}
+		failed[i] = err != nil
+	}
+	return failed
+}
+
+func equalFailed(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPublishCreates(t *testing.T) {
+	var tests = []struct {
+		name   string
+		fail   map[int]bool
+		err    error
+		failed []bool
+	}{
+		{"sent", nil, nil, []bool{false, false, false}},
+		{"one failed", map[int]bool{1: true}, nil, []bool{false, true, false}},
+		// errors that aren't per message fail every item.
+		{"batch failed", nil, errProduce, []bool{true, true, true}},
+	}
+
+	for _, test := range tests {
+		producer := &fakeProducer{fail: test.fail, err: test.err}
+		c := NewClient(producer, 4, "create")
+
+		var creates []*messaging.Create
+		for i := 0; i < 3; i++ {
+			creates = append(creates, &messaging.Create{Meta: &common.Meta{Domain: "acme", TimerUuid: uuid.New().String()}})
+		}
+
+		failed := getErrs(t, test.name, c.PublishCreates("acme", creates), errProduce)
+		if !equalFailed(failed, test.failed) {
+			t.Errorf("case: %v. expected failed %v, got %v", test.name, test.failed, failed)
+		}
+
+		if len(producer.sent) != len(creates) {
+			t.Errorf("case: %v. expected %v messages, got %v", test.name, len(creates), len(producer.sent))
+			continue
+		}
+
+		for _, msg := range producer.sent {
+			b, err := msg.Key.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := keys.Decode(b)
+			if err != nil {
+				t.Errorf("case: %v. unexpected error: %v", test.name, err)
+				continue
+			}
+
+			create := creates[msg.Metadata.(int)]
+			if key.Domain != "acme" || key.TimerUUID != create.Meta.TimerUuid || key.Kind != messaging.KeyKind_KEY_KIND_CREATE {
+				t.Errorf("case: %v. unexpected key %v for timer %v", test.name, key, create.Meta.TimerUuid)
+			}
+		}
+	}
+}
+
+func TestPublishDeletes(t *testing.T) {
+	var tests = []struct {
+		name   string
+		fail   map[int]bool
+		err    error
+		failed []bool
+	}{
+		{"sent", nil, nil, []bool{false, false}},
+		// a timer fails if any of its tombstones fails.
+		{"one failed", map[int]bool{0: true}, nil, []bool{true, false}},
+		{"batch failed", nil, errProduce, []bool{true, true}},
+	}
+
+	for _, test := range tests {
+		producer := &fakeProducer{fail: test.fail, err: test.err}
+		c := NewClient(producer, 4, "create")
+
+		ids := []uuid.UUID{uuid.New(), uuid.New()}
+		failed := getErrs(t, test.name, c.PublishDeletes("acme", ids), errProduce)
+		if !equalFailed(failed, test.failed) {
+			t.Errorf("case: %v. expected failed %v, got %v", test.name, test.failed, failed)
+		}
+
+		// every kind of record of each timer is tombstoned in every key version.
+		tombstones := map[int]int{}
+		for _, msg := range producer.sent {
+			if msg.Value != nil {
+				t.Errorf("case: %v. expected tombstone, got %v", test.name, msg.Value)
+			}
+
+			b, err := msg.Key.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := keys.Decode(b)
+			if err != nil {
+				t.Errorf("case: %v. unexpected error: %v", test.name, err)
+				continue
+			}
+
+			i := msg.Metadata.(int)
+			if key.TimerUUID != ids[i].String() {
+				t.Errorf("case: %v. tombstone of %v for item %v", test.name, key.TimerUUID, i)
+			}
+			tombstones[i]++
+		}
+
+		for i := range ids {
+			if tombstones[i] != 3*len(keys.Versions) {
+				t.Errorf("case: %v. expected %v tombstones of item %v, got %v", test.name, 3*len(keys.Versions), i, tombstones[i])
+			}
+		}
+	}
+}
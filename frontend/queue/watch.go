@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
+	"google.golang.org/protobuf/proto"
+)
+
+type (
+	// Watcher tails the execute topic.
+	Watcher interface {
+		// Watch sends executions from offsets, or from the newest offset of partitions without one, until ctx is done.
+		// It returns the offset each partition starts from.
+		Watch(ctx context.Context, offsets map[int32]int64) (map[int32]int64, <-chan *ExecuteRecord, error)
+	}
+
+	// ExecuteRecord is an execution recorded on the execute topic.
+	ExecuteRecord struct {
+		Key       *messaging.Key
+		Execute   *messaging.Execute
+		Partition int32
+		Offset    int64
+		Timestamp time.Time
+	}
+
+	// offsetClient is the part of sarama.Client the watcher uses.
+	offsetClient interface {
+		Partitions(topic string) ([]int32, error)
+		GetOffset(topic string, partition int32, time int64) (int64, error)
+	}
+
+	watcher struct {
+		client offsetClient
+		// newConsumer returns the consumer of a single watch.
+		// sarama consumers only consume a partition once at a time, so watches don't share them.
+		newConsumer func() (sarama.Consumer, error)
+		topic       string
+	}
+)
+
+// NewWatcher returns a new Watcher.
+func NewWatcher(client sarama.Client, topic string) Watcher {
+	return &watcher{
+		client: client,
+		newConsumer: func() (sarama.Consumer, error) {
+			return sarama.NewConsumerFromClient(client)
+		},
+		topic: topic,
+	}
+}
+
+// EncodeCursor returns the cursor of the offsets to continue from of every partition.
+func EncodeCursor(offsets map[int32]int64) (string, error) {
+	data, err := json.Marshal(offsets)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor returns the offsets of a cursor returned by EncodeCursor.
+func DecodeCursor(cursor string) (map[int32]int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets map[int32]int64
+	if err = json.Unmarshal(data, &offsets); err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+func (w *watcher) Watch(ctx context.Context, offsets map[int32]int64) (map[int32]int64, <-chan *ExecuteRecord, error) {
+	partitions, err := w.client.Partitions(w.topic)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// newest offsets are resolved now, so they can be resumed from before anything is recorded on them.
+	start := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, ok := offsets[partition]
+		if !ok {
+			offset, err = w.client.GetOffset(w.topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		start[partition] = offset
+	}
+
+	consumer, err := w.newConsumer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pcs []sarama.PartitionConsumer
+	for partition, offset := range start {
+		pc, err := consumer.ConsumePartition(w.topic, partition, offset)
+		if err != nil {
+			for _, pc := range pcs {
+				pc.AsyncClose()
+			}
+			consumer.Close()
+			return nil, nil, err
+		}
+		pcs = append(pcs, pc)
+	}
+
+	records := make(chan *ExecuteRecord)
+	var wg sync.WaitGroup
+	for _, pc := range pcs {
+		wg.Add(1)
+		go func(pc sarama.PartitionConsumer) {
+			defer wg.Done()
+			w.consumePartition(ctx, pc, records)
+		}(pc)
+	}
+
+	go func() {
+		<-ctx.Done()
+		for _, pc := range pcs {
+			pc.AsyncClose()
+		}
+		wg.Wait()
+		consumer.Close()
+		close(records)
+	}()
+
+	return start, records, nil
+}
+
+func (w *watcher) consumePartition(ctx context.Context, pc sarama.PartitionConsumer, records chan<- *ExecuteRecord) {
+	for msg := range pc.Messages() {
+		if msg.Key == nil || msg.Value == nil { // dummy messages and tombstones aren't executions.
+			continue
+		}
+
+		key, err := keys.Decode(msg.Key)
+		if err != nil {
+			fmt.Println("watch decode key:", err.Error())
+			continue
+		}
+
+		var execute messaging.Execute
+		if err := proto.Unmarshal(msg.Value, &execute); err != nil {
+			fmt.Println("watch unmarshal execute:", err.Error())
+			continue
+		}
+
+		select {
+		case records <- &ExecuteRecord{
+			Key:       key,
+			Execute:   &execute,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Timestamp: msg.Timestamp,
+		}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
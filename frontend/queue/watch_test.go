@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeOffsetClient has the same newest offset for every partition.
+type fakeOffsetClient struct {
+	partitions []int32
+	newest     int64
+}
+
+func (c fakeOffsetClient) Partitions(topic string) ([]int32, error) {
+	return c.partitions, nil
+}
+
+func (c fakeOffsetClient) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	return c.newest, nil
+}
+
+func TestConcurrentWatches(t *testing.T) {
+	first, second := mocks.NewConsumer(t, nil), mocks.NewConsumer(t, nil)
+	consumers := []sarama.Consumer{first, second}
+	w := &watcher{
+		client: fakeOffsetClient{partitions: []int32{0, 1}, newest: 10},
+		newConsumer: func() (sarama.Consumer, error) {
+			consumer := consumers[0]
+			consumers = consumers[1:]
+			return consumer, nil
+		},
+		topic: "execute",
+	}
+
+	// the first watch starts from the newest offsets, the second resumes partition 0.
+	firstPC := first.ExpectConsumePartition("execute", 0, 10)
+	first.ExpectConsumePartition("execute", 1, 10)
+	secondPC := second.ExpectConsumePartition("execute", 0, 4)
+	second.ExpectConsumePartition("execute", 1, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start, firstRecords, err := w.Watch(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(start, map[int32]int64{0: 10, 1: 10}) {
+		t.Errorf("unexpected start offsets %v", start)
+	}
+
+	// a second watch of the same partitions doesn't conflict with the first.
+	start, secondRecords, err := w.Watch(ctx, map[int32]int64{0: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(start, map[int32]int64{0: 4, 1: 10}) {
+		t.Errorf("unexpected start offsets %v", start)
+	}
+
+	key := &messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"}
+	legacyKey, err := proto.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentKey, err := keys.Encode(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := proto.Marshal(&messaging.Execute{Outcome: messaging.Outcome_OUTCOME_SUCCESS})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dummy messages and tombstones are skipped.
+	firstPC.YieldMessage(&sarama.ConsumerMessage{})
+	firstPC.YieldMessage(&sarama.ConsumerMessage{Key: currentKey})
+	firstPC.YieldMessage(&sarama.ConsumerMessage{Key: currentKey, Value: value})
+	secondPC.YieldMessage(&sarama.ConsumerMessage{Key: legacyKey, Value: value})
+
+	for name, records := range map[string]<-chan *ExecuteRecord{"first": firstRecords, "second": secondRecords} {
+		select {
+		case record := <-records:
+			if !proto.Equal(record.Key, key) || record.Execute.Outcome != messaging.Outcome_OUTCOME_SUCCESS || record.Partition != 0 {
+				t.Errorf("case: %v. unexpected record %v", name, record)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("case: %v. no record", name)
+		}
+	}
+
+	cancel()
+	for name, records := range map[string]<-chan *ExecuteRecord{"first": firstRecords, "second": secondRecords} {
+		select {
+		case record, ok := <-records:
+			if ok {
+				t.Errorf("case: %v. unexpected record %v", name, record)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("case: %v. records weren't closed", name)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	offsets := map[int32]int64{0: 5, 3: 12}
+	cursor, err := EncodeCursor(offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, offsets) {
+		t.Errorf("expected %v, got %v", offsets, decoded)
+	}
+
+	for _, invalid := range []string{"not base64!", "bm90IGpzb24"} {
+		if _, err := DecodeCursor(invalid); err == nil {
+			t.Errorf("case: %v. expected error", invalid)
+		}
+	}
+}
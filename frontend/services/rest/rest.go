@@ -8,6 +8,7 @@ import (
 	"github.com/nivista/steady/frontend/queue"
 	"github.com/nivista/steady/frontend/services/rest/auth"
 	"github.com/nivista/steady/frontend/services/rest/elastic"
+	"github.com/nivista/steady/frontend/services/rest/signing"
 	"github.com/nivista/steady/frontend/util"
 )
 
@@ -18,16 +19,18 @@ type app struct {
 	queue                        queue.Client
 	timersIndex, executionsIndex string
 	elasticURL                   *url.URL
+	signingKey                   string
 }
 
 // NewApp returns an http handler that handles the REST part of steady's API.
-func NewApp(db db.Client, queue queue.Client, timersIndex, executionsIndex string, elasticURL *url.URL) http.Handler {
+func NewApp(db db.Client, queue queue.Client, timersIndex, executionsIndex string, elasticURL *url.URL, signingKey string) http.Handler {
 	return app{
 		db:              db,
 		queue:           queue,
 		timersIndex:     timersIndex,
 		executionsIndex: executionsIndex,
 		elasticURL:      elasticURL,
+		signingKey:      signingKey,
 	}
 }
 
@@ -40,6 +43,8 @@ func (a app) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		auth.NewAuth(a.db).ServeHTTP(w, r)
 	case "elastic":
 		a.auth(w, r, elastic.NewElastic(a.timersIndex, a.executionsIndex, a.elasticURL))
+	case "signingsecret":
+		a.auth(w, r, signing.NewSigning(a.signingKey))
 
 	default:
 		w.WriteHeader(http.StatusNotFound)
@@ -0,0 +1,37 @@
+package signing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nivista/steady/frontend/util"
+	"github.com/nivista/steady/signature"
+)
+
+type signing struct {
+	signingKey string
+}
+
+// NewSigning returns a new handler that responds with the secret a domain's requests are signed with.
+func NewSigning(signingKey string) http.Handler {
+	return signing{
+		signingKey: signingKey,
+	}
+}
+
+func (s signing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientID, ok := util.GetClientID(r.Context())
+	if !ok {
+		fmt.Println("signing servehttp called without authentication")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if s.signingKey == "" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("request signing isn't enabled."))
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf("Signing Secret: %v\n", signature.DomainSecret(s.signingKey, clientID))))
+}
@@ -2,11 +2,20 @@ package rpc
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/google/uuid"
 	"github.com/nivista/steady/.gen/protos/common"
 	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/nivista/steady/elastic"
 	"github.com/nivista/steady/timer"
 
 	"github.com/nivista/steady/frontend/db"
@@ -16,21 +25,85 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+var validIdempotencyKey = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,128}$`)
+
+// page sizes of ListTimers and ListExecutions.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// maxBatchSize is the most timers BatchCreateTimers and BatchDeleteTimers take.
+const maxBatchSize = 1000
+
 type server struct {
-	queue queue.Client
+	queue   queue.Client
+	watcher queue.Watcher
+	db      db.Client
 }
 
 // NewServer returns a services.SteadyServer
-func NewServer(queue queue.Client) services.SteadyServer {
+func NewServer(queue queue.Client, watcher queue.Watcher, db db.Client) services.SteadyServer {
 	return &server{
-		queue: queue,
+		queue:   queue,
+		watcher: watcher,
+		db:      db,
 	}
 }
 
 func (s *server) CreateTimer(ctx context.Context, req *services.CreateTimerRequest) (*services.CreateTimerResponse, error) {
+	requestHash, err := getIdempotentRequestHash(req)
+	if err != nil {
+		return nil, err
+	}
+
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("CreateTimer got unauthenticated context.")
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	create, err := s.newCreate(ctx, domain, req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.IdempotencyKey != "" {
+		return s.createIdempotently(ctx, domain, req.IdempotencyKey, requestHash, create)
+	}
+
+	timerID, err := uuid.Parse(create.Meta.TimerUuid)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	if err = s.db.RegisterTimers(ctx, domain, []*messaging.Create{create})[0]; err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	err = s.queue.PublishCreate(domain, timerID, create)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.CreateTimerResponse{
+		TimerUuid: timerID.String(),
+	}, nil
+}
+
+// newCreate returns the validated timer of a CreateTimerRequest, with a new uuid.
+// calendars caches the domain's calendars across calls, it's filled in if not nil.
+func (s *server) newCreate(ctx context.Context, domain string, req *services.CreateTimerRequest, calendars map[string]*common.Calendar) (*messaging.Create, error) {
+	if req.Task == nil || req.Schedule == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "task and schedule are required")
+	}
+
 	// server time on server that recieves request is the default start time.
 	if req.Schedule.StartTime == nil {
 		req.Schedule.StartTime = timestamppb.Now()
@@ -38,13 +111,12 @@ func (s *server) CreateTimer(ctx context.Context, req *services.CreateTimerReque
 
 	timerID, err := uuid.NewRandom()
 	if err != nil {
-		return nil, err
+		return nil, grpc.Errorf(codes.Internal, err.Error())
 	}
 
-	domain, ok := util.GetClientID(ctx)
-	if !ok {
-		fmt.Println("CreateTimer got unauthenticated context.")
-		return nil, grpc.Errorf(codes.Internal, "")
+	timerCalendars, err := s.getCalendars(ctx, domain, req.Schedule.Calendars, calendars)
+	if err != nil {
+		return nil, err
 	}
 
 	create := messaging.Create{
@@ -52,7 +124,11 @@ func (s *server) CreateTimer(ctx context.Context, req *services.CreateTimerReque
 		Schedule: req.Schedule,
 		Meta: &common.Meta{
 			CreateTime: timestamppb.Now(),
+			Domain:     domain,
+			TimerUuid:  timerID.String(),
 		},
+		Calendars: timerCalendars,
+		Labels:    req.Labels,
 	}
 
 	err = timer.IsValid(&create)
@@ -60,66 +136,1075 @@ func (s *server) CreateTimer(ctx context.Context, req *services.CreateTimerReque
 		return nil, grpc.Errorf(codes.InvalidArgument, err.Error())
 	}
 
-	err = s.queue.PublishCreate(domain, timerID, &create)
+	return &create, nil
+}
+
+// createIdempotently publishes create, unless a request with the same idempotency key published a timer already.
+// If the earlier request reserved the key but didn't publish its timer, its timer is published.
+func (s *server) createIdempotently(ctx context.Context, domain, key, requestHash string, create *messaging.Create) (*services.CreateTimerResponse, error) {
+	record, publish, err := s.reserveIdempotencyKey(ctx, domain, key, requestHash, create)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Published {
+		return &services.CreateTimerResponse{TimerUuid: record.TimerUUID}, nil
+	}
+
+	timerID, err := uuid.Parse(record.TimerUUID)
 	if err != nil {
 		return nil, grpc.Errorf(codes.Internal, err.Error())
 	}
 
-	return &services.CreateTimerResponse{
-		TimerUuid: timerID.String(),
-	}, nil
+	if err = s.db.RegisterTimers(ctx, domain, []*messaging.Create{publish})[0]; err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	if err = s.queue.PublishCreate(domain, timerID, publish); err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	s.publishedIdempotencyKey(ctx, domain, key)
+	return &services.CreateTimerResponse{TimerUuid: record.TimerUUID}, nil
+}
+
+// reserveIdempotencyKey reserves a domain's idempotency key for create, the timer of a request.
+// It returns the record the key was first reserved with, and the timer to publish unless the record is published.
+func (s *server) reserveIdempotencyKey(ctx context.Context, domain, key, requestHash string, create *messaging.Create) (*elastic.Idempotency, *messaging.Create, error) {
+	createBytes, err := proto.Marshal(create)
+	if err != nil {
+		return nil, nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	record := &elastic.Idempotency{
+		RequestHash: requestHash,
+		TimerUUID:   create.Meta.TimerUuid,
+		Create:      createBytes,
+		CreateTime:  time.Now(),
+	}
+	existing, err := s.db.ReserveIdempotencyKey(ctx, domain, key, record)
+	if err != nil {
+		return nil, nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	if existing == nil {
+		return record, create, nil
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, nil, grpc.Errorf(codes.AlreadyExists, "idempotency_key was used with a different request")
+	}
+
+	// the timer of the first request is published if it wasn't, rather than this one.
+	var publish messaging.Create
+	if err = proto.Unmarshal(existing.Create, &publish); err != nil {
+		return nil, nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+	return existing, &publish, nil
+}
+
+// publishedIdempotencyKey records that the timer of a domain's idempotency key was published.
+func (s *server) publishedIdempotencyKey(ctx context.Context, domain, key string) {
+	// if this fails the timer is published again on retry, which restarts it with its progress.
+	if err := s.db.PublishedIdempotencyKey(ctx, domain, key); err != nil {
+		fmt.Printf("marking idempotency key %v published: %v\n", key, err)
+	}
 }
 
+// getIdempotentRequestHash validates the idempotency key of a request, and returns its hash if it has one.
+// It's hashed before defaults are applied, so retries hash the same.
+func getIdempotentRequestHash(req *services.CreateTimerRequest) (string, error) {
+	if req.IdempotencyKey == "" {
+		return "", nil
+	}
+
+	if !validIdempotencyKey.MatchString(req.IdempotencyKey) {
+		return "", grpc.Errorf(codes.InvalidArgument, "idempotency_key must be 1 to 128 letters, digits, underscores, dashes, dots or colons")
+	}
+
+	requestHash, err := getRequestHash(req)
+	if err != nil {
+		return "", grpc.Errorf(codes.Internal, err.Error())
+	}
+	return requestHash, nil
+}
+
+// getRequestHash returns the hash of a CreateTimerRequest, without its idempotency key.
+func getRequestHash(req *services.CreateTimerRequest) (string, error) {
+	req = proto.Clone(req).(*services.CreateTimerRequest)
+	req.IdempotencyKey = ""
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DeleteTimer only deletes timers of the caller's domain, other timers aren't found.
 func (s *server) DeleteTimer(ctx context.Context, req *services.DeleteTimerRequest) (*services.DeleteTimerResponse, error) {
-	id, err := uuid.Parse(req.TimerUuid)
+	domain, id, err := getDomainAndTimerID(ctx, "DeleteTimer", req.TimerUuid)
 	if err != nil {
 		return nil, err
 	}
 
+	// the registry rather than the timers index, which may not have the timer yet.
+	_, err = s.getRegisteredTimer(ctx, domain, id.String())
+	if errors.Is(err, db.ErrTimerNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, "timer not found")
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	err = s.queue.PublishDelete(domain, id)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	// unregistered after publishing, so a failed delete can be retried.
+	if err = s.db.UnregisterTimers(ctx, domain, []string{id.String()})[0]; err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.DeleteTimerResponse{}, nil
+}
+
+func (s *server) BatchCreateTimers(ctx context.Context, req *services.BatchCreateTimersRequest) (*services.BatchCreateTimersResponse, error) {
+	if len(req.Timers) > maxBatchSize {
+		return nil, grpc.Errorf(codes.InvalidArgument, "at most %v timers can be created in a batch", maxBatchSize)
+	}
+
 	domain, ok := util.GetClientID(ctx)
 	if !ok {
-		fmt.Println("DeleteTimer got unauthenticated context.")
+		fmt.Println("BatchCreateTimers got unauthenticated context.")
 		return nil, grpc.Errorf(codes.Internal, "")
 	}
 
-	err = s.queue.PublishDelete(domain, id)
+	results := make([]*services.BatchCreateTimerResult, len(req.Timers))
+	calendars := map[string]*common.Calendar{}
+	var (
+		creates []*messaging.Create
+		indexes []int
+		// keys are the idempotency keys of creates, empty for timers without one.
+		keys []string
+		// reserved are the items that reserved each key of the batch and their hashes,
+		// later items with the key and hash get their result.
+		reserved       = map[string]int{}
+		reservedHashes = map[string]string{}
+		duplicates     = map[int]int{}
+	)
+	// every key is reserved before any timer is published, so a failure only affects its own item.
+	for i, timerReq := range req.Timers {
+		results[i] = &services.BatchCreateTimerResult{}
+		requestHash, err := getIdempotentRequestHash(timerReq)
+		if err != nil {
+			results[i].Error = getBatchError(err)
+			continue
+		}
+
+		create, err := s.newCreate(ctx, domain, timerReq, calendars)
+		if err != nil {
+			results[i].Error = getBatchError(err)
+			continue
+		}
+
+		key := timerReq.IdempotencyKey
+		if key == "" {
+			creates, indexes, keys = append(creates, create), append(indexes, i), append(keys, "")
+			continue
+		}
+
+		// timers with the same key in a batch are one timer, like retries.
+		if first, ok := reserved[key]; ok {
+			if reservedHashes[key] != requestHash {
+				results[i].Error = getBatchError(grpc.Errorf(codes.AlreadyExists, "idempotency_key was used with a different request"))
+			} else {
+				duplicates[i] = first
+			}
+			continue
+		}
+
+		record, publish, err := s.reserveIdempotencyKey(ctx, domain, key, requestHash, create)
+		if err != nil {
+			results[i].Error = getBatchError(err)
+			continue
+		}
+		reserved[key], reservedHashes[key] = i, requestHash
+
+		if record.Published {
+			results[i].TimerUuid = record.TimerUUID
+			continue
+		}
+		creates, indexes, keys = append(creates, publish), append(indexes, i), append(keys, key)
+	}
+
+	var (
+		registered        []*messaging.Create
+		registeredIndexes []int
+		registeredKeys    []string
+	)
+	for j, err := range s.db.RegisterTimers(ctx, domain, creates) {
+		if err != nil {
+			results[indexes[j]].Error = getBatchError(grpc.Errorf(codes.Internal, err.Error()))
+			continue
+		}
+
+		registered = append(registered, creates[j])
+		registeredIndexes = append(registeredIndexes, indexes[j])
+		registeredKeys = append(registeredKeys, keys[j])
+	}
+	creates, indexes, keys = registered, registeredIndexes, registeredKeys
+
+	errs := s.queue.PublishCreates(domain, creates)
+	for j, err := range errs {
+		result := results[indexes[j]]
+		if err != nil {
+			result.Error = getBatchError(grpc.Errorf(codes.Internal, err.Error()))
+			continue
+		}
+		result.TimerUuid = creates[j].Meta.TimerUuid
+
+		if keys[j] != "" {
+			s.publishedIdempotencyKey(ctx, domain, keys[j])
+		}
+	}
+
+	for i, first := range duplicates {
+		results[i].TimerUuid, results[i].Error = results[first].TimerUuid, results[first].Error
+	}
+
+	return &services.BatchCreateTimersResponse{Results: results}, nil
+}
+
+func (s *server) BatchDeleteTimers(ctx context.Context, req *services.BatchDeleteTimersRequest) (*services.BatchDeleteTimersResponse, error) {
+	if len(req.TimerUuids) > maxBatchSize {
+		return nil, grpc.Errorf(codes.InvalidArgument, "at most %v timers can be deleted in a batch", maxBatchSize)
+	}
+
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("BatchDeleteTimers got unauthenticated context.")
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	results := make([]*services.BatchDeleteTimerResult, len(req.TimerUuids))
+	var (
+		ids     []uuid.UUID
+		idStrs  []string
+		indexes []int
+	)
+	for i, timerUUID := range req.TimerUuids {
+		results[i] = &services.BatchDeleteTimerResult{TimerUuid: timerUUID}
+		id, err := uuid.Parse(timerUUID)
+		if err != nil {
+			results[i].Error = getBatchError(grpc.Errorf(codes.InvalidArgument, err.Error()))
+			continue
+		}
+
+		ids = append(ids, id)
+		idStrs = append(idStrs, id.String())
+		indexes = append(indexes, i)
+	}
+
+	// like DeleteTimer, timers of other domains aren't found.
+	owned, err := s.getOwnedTimerIDs(ctx, domain, idStrs)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	var (
+		ownedIDs     []uuid.UUID
+		ownedIndexes []int
+	)
+	for j, id := range ids {
+		if !owned[id.String()] {
+			results[indexes[j]].Error = getBatchError(grpc.Errorf(codes.NotFound, "timer not found"))
+			continue
+		}
+
+		ownedIDs = append(ownedIDs, id)
+		ownedIndexes = append(ownedIndexes, indexes[j])
+	}
+	ids, indexes = ownedIDs, ownedIndexes
+
+	var (
+		published        []string
+		publishedIndexes []int
+	)
+	for j, err := range s.queue.PublishDeletes(domain, ids) {
+		if err != nil {
+			results[indexes[j]].Error = getBatchError(grpc.Errorf(codes.Internal, err.Error()))
+			continue
+		}
+
+		published = append(published, ids[j].String())
+		publishedIndexes = append(publishedIndexes, indexes[j])
+	}
+
+	for j, err := range s.db.UnregisterTimers(ctx, domain, published) {
+		if err != nil {
+			results[publishedIndexes[j]].Error = getBatchError(grpc.Errorf(codes.Internal, err.Error()))
+		}
+	}
+
+	return &services.BatchDeleteTimersResponse{Results: results}, nil
+}
+
+// getOwnedTimerIDs returns which of the canonical ids are timers of the domain that aren't deleted.
+// Timers the registry has no record of, created before it, are looked up in the timers index.
+func (s *server) getOwnedTimerIDs(ctx context.Context, domain string, ids []string) (map[string]bool, error) {
+	owned, err := s.db.GetRegisteredTimerIDs(ctx, domain, ids)
 	if err != nil {
 		return nil, err
 	}
 
-	return &services.DeleteTimerResponse{}, nil
+	var unregistered []string
+	for _, id := range ids {
+		if _, ok := owned[id]; !ok {
+			unregistered = append(unregistered, id)
+		}
+	}
+
+	indexed, err := s.db.GetTimerIDs(ctx, domain, unregistered)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, found := range indexed {
+		owned[id] = found
+	}
+	return owned, nil
 }
 
-// GetAuth returns a UnaryServerInterceptor that authenticates requests.
-func GetAuth(client db.Client) grpc.UnaryServerInterceptor {
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, grpc.Errorf(codes.Internal, "")
+// getBatchError returns the BatchError of an item that failed with err.
+func getBatchError(err error) *services.BatchError {
+	st := status.Convert(err)
+	return &services.BatchError{
+		Code:    int32(st.Code()),
+		Message: st.Message(),
+	}
+}
+
+func (s *server) GetTimer(ctx context.Context, req *services.GetTimerRequest) (*services.GetTimerResponse, error) {
+	domain, id, err := getDomainAndTimerID(ctx, "GetTimer", req.TimerUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := s.db.GetTimer(ctx, domain, id.String())
+	if errors.Is(err, db.ErrTimerNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, "timer not found")
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	timers, err := s.getTimers(ctx, []*db.Timer{t})
+	if err != nil {
+		return nil, err
+	}
+
+	return &services.GetTimerResponse{Timer: timers[0]}, nil
+}
+
+func (s *server) ListTimers(ctx context.Context, req *services.ListTimersRequest) (*services.ListTimersResponse, error) {
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("ListTimers got unauthenticated context.")
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	size, err := getPageSize(req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.PageToken != "" {
+		if _, err := uuid.Parse(req.PageToken); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid page_token")
 		}
+	}
 
-		s := md.Get("Authorization")
-		if len(s) == 0 {
-			return nil, grpc.Errorf(codes.Unauthenticated, "")
+	filter := db.TimerFilter{Labels: req.Labels}
+	switch req.State {
+	case services.TimerState_TIMER_STATE_UNSPECIFIED:
+	case services.TimerState_TIMER_STATE_ACTIVE, services.TimerState_TIMER_STATE_PAUSED:
+		paused := req.State == services.TimerState_TIMER_STATE_PAUSED
+		filter.Paused = &paused
+	case services.TimerState_TIMER_STATE_FINISHED:
+		return nil, grpc.Errorf(codes.InvalidArgument, "finished timers can't be listed by state")
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "unknown state")
+	}
+
+	ts, err := s.db.ListTimers(ctx, domain, filter, size, req.PageToken)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	timers, err := s.getTimers(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := services.ListTimersResponse{Timers: timers}
+	if len(timers) == size { // the page is full, so there may be another.
+		res.NextPageToken = timers[len(timers)-1].TimerUuid
+	}
+	return &res, nil
+}
+
+func (s *server) ListExecutions(ctx context.Context, req *services.ListExecutionsRequest) (*services.ListExecutionsResponse, error) {
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("ListExecutions got unauthenticated context.")
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	size, err := getPageSize(req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// page tokens are the encoded sort values of the last execution of the previous page.
+	var after json.RawMessage
+	if req.PageToken != "" {
+		after, err = base64.RawURLEncoding.DecodeString(req.PageToken)
+		if err != nil || !db.IsExecutionsCursor(after) {
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid page_token")
 		}
+	}
 
-		clientID, clientSecret, ok := util.ParseBasicAuth(s[0])
-		if !ok {
-			return nil, grpc.Errorf(codes.Unauthenticated, "")
+	filter := db.ExecutionFilter{TimerUUID: req.TimerUuid}
+	if req.TimerUuid != "" {
+		if _, err := uuid.Parse(req.TimerUuid); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, err.Error())
 		}
+	}
 
-		err = client.AuthenticateUser(ctx, clientID, clientSecret)
-		if err != nil {
-			switch err.(type) {
-			case db.InvalidAPIToken:
-				return nil, grpc.Errorf(codes.Unauthenticated, "")
+	if req.StartTime != nil {
+		if err := req.StartTime.CheckValid(); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid start_time: %v", err)
+		}
+		filter.Start = req.StartTime.AsTime()
+	}
 
-			case db.InvalidAPISecret:
-				return nil, grpc.Errorf(codes.Unauthenticated, "")
-			}
+	if req.EndTime != nil {
+		if err := req.EndTime.CheckValid(); err != nil {
+			return nil, grpc.Errorf(codes.InvalidArgument, "invalid end_time: %v", err)
+		}
+		filter.End = req.EndTime.AsTime()
+	}
+
+	switch req.Outcome {
+	case services.ExecutionOutcome_EXECUTION_OUTCOME_UNSPECIFIED:
+	case services.ExecutionOutcome_EXECUTION_OUTCOME_SUCCESS:
+		filter.Outcome = elastic.OutcomeSuccess
+	case services.ExecutionOutcome_EXECUTION_OUTCOME_FAILURE:
+		filter.Outcome = elastic.OutcomeFailure
+	case services.ExecutionOutcome_EXECUTION_OUTCOME_SKIPPED:
+		filter.Outcome = elastic.OutcomeSkipped
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "unknown outcome")
+	}
+
+	records, last, err := s.db.ListExecutions(ctx, domain, filter, size, after)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
 
-			return nil, grpc.Errorf(codes.Internal, "")
+	executions := make([]*services.Execution, 0, len(records))
+	for _, record := range records {
+		execution := services.Execution{
+			TimerUuid:  record.TimerUUID,
+			RecordTime: timestamppb.New(record.KafkaTimestamp),
+			Outcome:    getExecutionOutcome(record.Outcome),
+			Manual:     record.Manual,
+			TriggerId:  record.TriggerID,
+			Duration:   durationpb.New(record.Duration),
 		}
-		return handler(util.SetClientID(ctx, clientID), req)
+		setResult(&execution, record.Result)
+
+		executions = append(executions, &execution)
+	}
+
+	res := services.ListExecutionsResponse{Executions: executions}
+	if len(executions) == size && last != nil { // the page is full, so there may be another.
+		res.NextPageToken = base64.RawURLEncoding.EncodeToString(last)
+	}
+	return &res, nil
+}
+
+func (s *server) WatchExecutions(req *services.WatchExecutionsRequest, stream services.Steady_WatchExecutionsServer) error {
+	ctx := stream.Context()
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("WatchExecutions got unauthenticated context.")
+		return grpc.Errorf(codes.Internal, "")
+	}
+
+	timerUUIDs := make(map[string]bool, len(req.TimerUuids))
+	for _, id := range req.TimerUuids {
+		if _, err := uuid.Parse(id); err != nil {
+			return grpc.Errorf(codes.InvalidArgument, err.Error())
+		}
+		timerUUIDs[id] = true
+	}
+
+	// cursors are the encoded offset to continue from of every partition of the execute topic.
+	var offsets map[int32]int64
+	if req.Cursor != "" {
+		var err error
+		if offsets, err = queue.DecodeCursor(req.Cursor); err != nil {
+			return grpc.Errorf(codes.InvalidArgument, "invalid cursor")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	offsets, records, err := s.watcher.Watch(ctx, offsets)
+	if errors.Is(err, sarama.ErrOffsetOutOfRange) {
+		return grpc.Errorf(codes.OutOfRange, "cursor is too old")
+	} else if err != nil {
+		return grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	for record := range records {
+		offsets[record.Partition] = record.Offset + 1
+
+		if record.Key.Domain != domain || (len(timerUUIDs) > 0 && !timerUUIDs[record.Key.TimerUUID]) {
+			continue
+		}
+
+		cursor, err := queue.EncodeCursor(offsets)
+		if err != nil {
+			return grpc.Errorf(codes.Internal, err.Error())
+		}
+
+		execution := services.Execution{
+			TimerUuid:  record.Key.TimerUUID,
+			RecordTime: timestamppb.New(record.Timestamp),
+			Outcome:    getMessagingOutcome(record.Execute.Outcome),
+			Manual:     record.Execute.Manual,
+			TriggerId:  record.Execute.TriggerId,
+			Duration:   record.Execute.Duration,
+		}
+		setResult(&execution, record.Execute.Result)
+
+		err = stream.Send(&services.WatchExecutionsResponse{
+			Execution: &execution,
+			Cursor:    cursor,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setResult sets the attempts of an execution from its recorded result.
+func setResult(execution *services.Execution, result []byte) {
+	res, err := timer.ParseResult(result)
+	if err != nil {
+		fmt.Printf("parsing result of timer %v: %v\n", execution.TimerUuid, err)
+	}
+
+	execution.SkippedCalendar = res.SkippedCalendar
+	for _, a := range res.Attempts {
+		execution.Attempts = append(execution.Attempts, &services.ExecutionAttempt{
+			StatusCode: int32(a.StatusCode),
+			Error:      a.Error,
+			ErrorKind:  a.ErrorKind,
+			Body:       a.Body,
+			Failure:    a.Failure,
+		})
+	}
+}
+
+func getMessagingOutcome(outcome messaging.Outcome) services.ExecutionOutcome {
+	switch outcome {
+	case messaging.Outcome_OUTCOME_SUCCESS:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_SUCCESS
+	case messaging.Outcome_OUTCOME_FAILURE:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_FAILURE
+	case messaging.Outcome_OUTCOME_SKIPPED:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_SKIPPED
+	default:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_UNSPECIFIED
+	}
+}
+
+func getExecutionOutcome(outcome string) services.ExecutionOutcome {
+	switch outcome {
+	case elastic.OutcomeSuccess:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_SUCCESS
+	case elastic.OutcomeFailure:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_FAILURE
+	case elastic.OutcomeSkipped:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_SKIPPED
+	default:
+		return services.ExecutionOutcome_EXECUTION_OUTCOME_UNSPECIFIED
+	}
+}
+
+// getPageSize validates the page size of a list request, and applies the default and maximum.
+func getPageSize(pageSize int32) (int, error) {
+	size := int(pageSize)
+	if size < 0 {
+		return 0, grpc.Errorf(codes.InvalidArgument, "page_size can't be negative")
+	} else if size == 0 {
+		size = defaultPageSize
+	} else if size > maxPageSize {
+		size = maxPageSize
+	}
+	return size, nil
+}
+
+// getTimers returns the timers with their progress and next fire time.
+func (s *server) getTimers(ctx context.Context, ts []*db.Timer) ([]*services.Timer, error) {
+	ids := make([]string, 0, len(ts))
+	for _, t := range ts {
+		ids = append(ids, t.Create.Meta.GetTimerUuid())
+	}
+
+	progresses, err := s.db.GetProgresses(ctx, ids)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	now := time.Now()
+	timers := make([]*services.Timer, 0, len(ts))
+	for _, t := range ts {
+		id := t.Create.Meta.GetTimerUuid()
+		prog := timer.CurrentProgress(t.Create, progresses[id])
+
+		res := services.Timer{
+			TimerUuid:  id,
+			Task:       t.Create.Task,
+			Schedule:   t.Create.Schedule,
+			Labels:     t.Create.Labels,
+			State:      services.TimerState_TIMER_STATE_ACTIVE,
+			CreateTime: t.Create.Meta.GetCreateTime(),
+			UpdateTime: t.Create.Meta.GetUpdateTime(),
+		}
+
+		if prog != nil {
+			res.Progress = &services.TimerProgress{
+				CompletedExecutions: prog.CompletedExecutions,
+				LastExecution:       prog.LastExecution,
+				LastScheduled:       prog.LastScheduled,
+			}
+		}
+
+		if t.Paused {
+			res.State = services.TimerState_TIMER_STATE_PAUSED
+		} else if next, err := timer.NextFire(t.Create, prog, now); err != nil {
+			fmt.Printf("next fire of timer %v: %v\n", id, err)
+		} else if next.IsZero() {
+			// finished timers are listed until their tombstone is indexed.
+			res.State = services.TimerState_TIMER_STATE_FINISHED
+		} else {
+			res.NextFireTime = timestamppb.New(next)
+		}
+
+		timers = append(timers, &res)
+	}
+
+	return timers, nil
+}
+
+func (s *server) UpdateTimer(ctx context.Context, req *services.UpdateTimerRequest) (*services.UpdateTimerResponse, error) {
+	domain, id, err := getDomainAndTimerID(ctx, "UpdateTimer", req.TimerUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.UpdateMask.GetPaths()) == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "update_mask is required")
+	}
+
+	// the registry rather than the timers index, which may not have the latest update or delete yet.
+	t, err := s.getRegisteredTimer(ctx, domain, id.String())
+	if errors.Is(err, db.ErrTimerNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, "timer not found")
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	// the mask is applied to a CreateTimerRequest, so paths are relative to the fields it shares with the request.
+	updated := &services.CreateTimerRequest{Task: t.Create.Task, Schedule: t.Create.Schedule, Labels: t.Create.Labels}
+	err = util.ApplyFieldMask(updated, &services.CreateTimerRequest{Task: req.Task, Schedule: req.Schedule, Labels: req.Labels}, req.UpdateMask.Paths)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	if updated.Task == nil || updated.Schedule == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "task and schedule can't be cleared")
+	}
+
+	if updated.Schedule.StartTime == nil {
+		updated.Schedule.StartTime = timestamppb.Now()
+	}
+
+	calendars, err := s.getCalendars(ctx, domain, updated.Schedule.Calendars, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	create := &messaging.Create{
+		Task:     updated.Task,
+		Schedule: updated.Schedule,
+		Labels:   updated.Labels,
+		Meta: &common.Meta{
+			CreateTime: t.Create.Meta.GetCreateTime(),
+			UpdateTime: timestamppb.Now(),
+			Domain:     domain,
+			TimerUuid:  id.String(),
+		},
+		Calendars:     calendars,
+		ResetProgress: req.ResetProgress,
+	}
+
+	err = timer.IsValid(create)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.db.UpdateRegisteredTimer(ctx, domain, t, create)
+	if errors.Is(err, db.ErrRegistrationConflict) {
+		return nil, grpc.Errorf(codes.Aborted, "timer was changed concurrently, retry the update")
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	err = s.queue.PublishCreate(domain, id, create)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.UpdateTimerResponse{}, nil
+}
+
+// getRegisteredTimer returns a timer of the domain from the registry.
+// Timers created before the registry are registered from the timers index first.
+func (s *server) getRegisteredTimer(ctx context.Context, domain, id string) (*db.RegisteredTimer, error) {
+	t, err := s.db.GetRegisteredTimer(ctx, domain, id)
+	if !errors.Is(err, db.ErrTimerNotRegistered) {
+		return t, err
+	}
+
+	indexed, err := s.db.GetTimer(ctx, domain, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.db.RegisterTimers(ctx, domain, []*messaging.Create{indexed.Create})[0]; err != nil {
+		return nil, err
+	}
+	return s.db.GetRegisteredTimer(ctx, domain, id)
+}
+
+func (s *server) PauseTimer(ctx context.Context, req *services.PauseTimerRequest) (*services.PauseTimerResponse, error) {
+	domain, id, err := getDomainAndTimerID(ctx, "PauseTimer", req.TimerUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	// states of timers that don't exist would be kept by runtimers until they restart.
+	_, err = s.getRegisteredTimer(ctx, domain, id.String())
+	if errors.Is(err, db.ErrTimerNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, "timer not found")
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	if err = s.queue.PublishState(domain, id, &messaging.State{Paused: true}); err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.PauseTimerResponse{}, nil
+}
+
+func (s *server) ResumeTimer(ctx context.Context, req *services.ResumeTimerRequest) (*services.ResumeTimerResponse, error) {
+	domain, id, err := getDomainAndTimerID(ctx, "ResumeTimer", req.TimerUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	// states of timers that don't exist would be kept by runtimers until they restart.
+	_, err = s.getRegisteredTimer(ctx, domain, id.String())
+	if errors.Is(err, db.ErrTimerNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, "timer not found")
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	if err = s.queue.PublishState(domain, id, &messaging.State{Paused: false}); err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.ResumeTimerResponse{}, nil
+}
+
+func (s *server) TriggerTimer(ctx context.Context, req *services.TriggerTimerRequest) (*services.TriggerTimerResponse, error) {
+	domain, id, err := getDomainAndTimerID(ctx, "TriggerTimer", req.TimerUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	// runtimers drop triggers of timers they don't have, so they're rejected rather than reported as published.
+	_, err = s.getRegisteredTimer(ctx, domain, id.String())
+	if errors.Is(err, db.ErrTimerNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, "timer not found")
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	triggerID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.queue.PublishTrigger(domain, id, &messaging.Trigger{TriggerId: triggerID.String()}); err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.TriggerTimerResponse{TriggerId: triggerID.String()}, nil
+}
+
+// getCalendars returns the domain's calendars with the given names.
+// Timers keep a copy of the calendars, so runtimers don't depend on the database, and PutCalendar republishes them.
+// cache holds calendars already fetched, it's filled in if not nil.
+func (s *server) getCalendars(ctx context.Context, domain string, names []string, cache map[string]*common.Calendar) ([]*common.Calendar, error) {
+	calendars := make([]*common.Calendar, 0, len(names))
+	for _, name := range names {
+		if calendar, ok := cache[name]; ok {
+			calendars = append(calendars, calendar)
+			continue
+		}
+
+		calendar, err := s.db.GetCalendar(ctx, domain, name)
+		if errors.Is(err, db.ErrCalendarNotFound) {
+			return nil, grpc.Errorf(codes.InvalidArgument, "unknown calendar %v", name)
+		} else if err != nil {
+			return nil, grpc.Errorf(codes.Internal, err.Error())
+		}
+		if cache != nil {
+			cache[name] = calendar
+		}
+		calendars = append(calendars, calendar)
+	}
+	return calendars, nil
+}
+
+// getDomainAndTimerID returns the domain of the request and the id of the timer it refers to.
+func getDomainAndTimerID(ctx context.Context, method, timerUUID string) (string, uuid.UUID, error) {
+	id, err := uuid.Parse(timerUUID)
+	if err != nil {
+		return "", uuid.UUID{}, grpc.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Printf("%v got unauthenticated context.\n", method)
+		return "", uuid.UUID{}, grpc.Errorf(codes.Internal, "")
+	}
+
+	return domain, id, nil
+}
+
+func (s *server) PutCalendar(ctx context.Context, req *services.PutCalendarRequest) (*services.PutCalendarResponse, error) {
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("PutCalendar got unauthenticated context.")
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	if err := timer.IsValidCalendar(req.Calendar); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.db.PutCalendar(ctx, domain, req.Calendar); err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	if err := s.republishCalendar(ctx, domain, req.Calendar); err != nil {
+		return nil, err
+	}
+
+	return &services.PutCalendarResponse{}, nil
+}
+
+// republishCalendar publishes the timers that refer to the calendar again, with their copy of it replaced.
+// Meta is kept as it is, so the progress of timers updated with reset_progress isn't reset again.
+func (s *server) republishCalendar(ctx context.Context, domain string, calendar *common.Calendar) error {
+	timers, err := s.getCalendarTimers(ctx, domain, calendar.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range timers {
+		timerID, err := uuid.Parse(t.Create.Meta.GetTimerUuid())
+		if err != nil {
+			return grpc.Errorf(codes.Internal, err.Error())
+		}
+
+		create := proto.Clone(t.Create).(*messaging.Create)
+		for i, c := range create.Calendars {
+			if c.Name == calendar.Name {
+				create.Calendars[i] = calendar
+			}
+		}
+
+		err = s.db.UpdateRegisteredTimer(ctx, domain, t, create)
+		if errors.Is(err, db.ErrRegistrationConflict) {
+			return grpc.Errorf(codes.Aborted, "timer %v was changed concurrently, retry putting the calendar", timerID)
+		} else if err != nil {
+			return grpc.Errorf(codes.Internal, err.Error())
+		}
+
+		if err = s.queue.PublishCreate(domain, timerID, create); err != nil {
+			return grpc.Errorf(codes.Internal, err.Error())
+		}
+	}
+	return nil
+}
+
+// getCalendarTimers returns the domain's registered timers that refer to the calendar.
+func (s *server) getCalendarTimers(ctx context.Context, domain, name string) ([]*db.RegisteredTimer, error) {
+	ids, err := s.db.GetCalendarTimerIDs(ctx, domain, name)
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	var timers []*db.RegisteredTimer
+	for _, id := range ids {
+		t, err := s.getRegisteredTimer(ctx, domain, id)
+		if errors.Is(err, db.ErrTimerNotFound) {
+			continue
+		} else if err != nil {
+			return nil, grpc.Errorf(codes.Internal, err.Error())
+		}
+
+		// the timer may have been updated since it was found.
+		for _, c := range t.Create.Calendars {
+			if c.Name == name {
+				timers = append(timers, t)
+				break
+			}
+		}
+	}
+	return timers, nil
+}
+
+func (s *server) GetCalendar(ctx context.Context, req *services.GetCalendarRequest) (*services.GetCalendarResponse, error) {
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("GetCalendar got unauthenticated context.")
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	calendar, err := s.db.GetCalendar(ctx, domain, req.Name)
+	if errors.Is(err, db.ErrCalendarNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.GetCalendarResponse{
+		Calendar: calendar,
+	}, nil
+}
+
+func (s *server) DeleteCalendar(ctx context.Context, req *services.DeleteCalendarRequest) (*services.DeleteCalendarResponse, error) {
+	domain, ok := util.GetClientID(ctx)
+	if !ok {
+		fmt.Println("DeleteCalendar got unauthenticated context.")
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	// updates of timers that refer to a deleted calendar would fail, since their calendars can't be found.
+	timers, err := s.getCalendarTimers(ctx, domain, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(timers) > 0 {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "calendar is used by %v timers, like %v", len(timers), timers[0].Create.Meta.GetTimerUuid())
+	}
+
+	err = s.db.DeleteCalendar(ctx, domain, req.Name)
+	if errors.Is(err, db.ErrCalendarNotFound) {
+		return nil, grpc.Errorf(codes.NotFound, err.Error())
+	} else if err != nil {
+		return nil, grpc.Errorf(codes.Internal, err.Error())
+	}
+
+	return &services.DeleteCalendarResponse{}, nil
+}
+
+// GetAuth returns a UnaryServerInterceptor that authenticates requests.
+func GetAuth(client db.Client) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, err = authenticate(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GetStreamAuth is GetAuth for streaming RPCs.
+func GetStreamAuth(client db.Client) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), client)
+		if err != nil {
+			return err
+		}
+		return handler(srv, authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticatedStream is a stream with the context returned by authenticate.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate returns a context with the client ID of the request, if it has valid credentials.
+func authenticate(ctx context.Context, client db.Client) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, grpc.Errorf(codes.Internal, "")
+	}
+
+	s := md.Get("Authorization")
+	if len(s) == 0 {
+		return nil, grpc.Errorf(codes.Unauthenticated, "")
+	}
+
+	clientID, clientSecret, ok := util.ParseBasicAuth(s[0])
+	if !ok {
+		return nil, grpc.Errorf(codes.Unauthenticated, "")
+	}
+
+	err := client.AuthenticateUser(ctx, clientID, clientSecret)
+	if err != nil {
+		switch err.(type) {
+		case db.InvalidAPIToken:
+			return nil, grpc.Errorf(codes.Unauthenticated, "")
+
+		case db.InvalidAPISecret:
+			return nil, grpc.Errorf(codes.Unauthenticated, "")
+		}
+
+		return nil, grpc.Errorf(codes.Internal, "")
 	}
+	return util.SetClientID(ctx, clientID), nil
 }
@@ -0,0 +1,837 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/.gen/protos/services"
+	"github.com/nivista/steady/elastic"
+	"github.com/nivista/steady/frontend/db"
+	"github.com/nivista/steady/frontend/queue"
+	"github.com/nivista/steady/frontend/util"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	testDomain = "acme"
+	testUUID   = "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"
+)
+
+// fakeDB keeps the registry and timers index of testDomain in memory, other domains have no timers.
+// Other methods panic.
+type fakeDB struct {
+	db.Client
+	registry         map[string]*db.RegisteredTimer // nil registrations are deleted timers.
+	timers           map[string]*db.Timer
+	progresses       map[string]*messaging.Progress
+	idempotency      map[string]*elastic.Idempotency
+	deletedCalendars []string
+	// afterGet is called after a registered timer is read.
+	afterGet func()
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{
+		registry:    map[string]*db.RegisteredTimer{},
+		timers:      map[string]*db.Timer{},
+		idempotency: map[string]*elastic.Idempotency{},
+		afterGet:    func() {},
+	}
+}
+
+func (f *fakeDB) GetTimer(ctx context.Context, domain, id string) (*db.Timer, error) {
+	t, ok := f.timers[id]
+	if !ok || domain != testDomain {
+		return nil, db.ErrTimerNotFound
+	}
+	return t, nil
+}
+
+func (f *fakeDB) GetProgresses(ctx context.Context, ids []string) (map[string]*messaging.Progress, error) {
+	return f.progresses, nil
+}
+
+func (f *fakeDB) GetTimerIDs(ctx context.Context, domain string, ids []string) (map[string]bool, error) {
+	found := map[string]bool{}
+	for _, id := range ids {
+		if _, ok := f.timers[id]; ok {
+			found[id] = true
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeDB) GetRegisteredTimerIDs(ctx context.Context, domain string, ids []string) (map[string]bool, error) {
+	registered := map[string]bool{}
+	for _, id := range ids {
+		if t, ok := f.registry[id]; ok {
+			registered[id] = t != nil
+		}
+	}
+	return registered, nil
+}
+
+func (f *fakeDB) RegisterTimers(ctx context.Context, domain string, creates []*messaging.Create) []error {
+	for _, create := range creates {
+		if _, ok := f.registry[create.Meta.TimerUuid]; !ok {
+			f.registry[create.Meta.TimerUuid] = &db.RegisteredTimer{Create: create}
+		}
+	}
+	return make([]error, len(creates))
+}
+
+func (f *fakeDB) GetRegisteredTimer(ctx context.Context, domain, id string) (*db.RegisteredTimer, error) {
+	defer f.afterGet()
+
+	t, ok := f.registry[id]
+	if !ok || domain != testDomain {
+		return nil, db.ErrTimerNotRegistered
+	}
+	if t == nil {
+		return nil, db.ErrTimerNotFound
+	}
+	registered := *t
+	return &registered, nil
+}
+
+func (f *fakeDB) UpdateRegisteredTimer(ctx context.Context, domain string, t *db.RegisteredTimer, create *messaging.Create) error {
+	id := create.Meta.TimerUuid
+	if current := f.registry[id]; current == nil || current.SeqNo != t.SeqNo {
+		return db.ErrRegistrationConflict
+	}
+	f.registry[id] = &db.RegisteredTimer{Create: create, SeqNo: t.SeqNo + 1}
+	return nil
+}
+
+func (f *fakeDB) ReserveIdempotencyKey(ctx context.Context, domain, key string, record *elastic.Idempotency) (*elastic.Idempotency, error) {
+	if existing, ok := f.idempotency[key]; ok {
+		reserved := *existing
+		return &reserved, nil
+	}
+	reserved := *record
+	f.idempotency[key] = &reserved
+	return nil, nil
+}
+
+func (f *fakeDB) PublishedIdempotencyKey(ctx context.Context, domain, key string) error {
+	f.idempotency[key].Published = true
+	return nil
+}
+
+func (f *fakeDB) PutCalendar(ctx context.Context, domain string, calendar *common.Calendar) error {
+	return nil
+}
+
+// GetCalendarTimerIDs returns every registered timer, so timers that don't refer to the calendar are tested too.
+func (f *fakeDB) GetCalendarTimerIDs(ctx context.Context, domain, name string) ([]string, error) {
+	var ids []string
+	for id := range f.registry {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (f *fakeDB) DeleteCalendar(ctx context.Context, domain, name string) error {
+	f.deletedCalendars = append(f.deletedCalendars, name)
+	return nil
+}
+
+func (f *fakeDB) UnregisterTimers(ctx context.Context, domain string, ids []string) []error {
+	for _, id := range ids {
+		f.registry[id] = nil
+	}
+	return make([]error, len(ids))
+}
+
+// fakeQueue records the creates, deletes, states and triggers it publishes, other methods panic.
+type fakeQueue struct {
+	queue.Client
+	creates  []*messaging.Create
+	deletes  []uuid.UUID
+	states   []*messaging.State
+	triggers []*messaging.Trigger
+	batches  int // calls to PublishCreates.
+}
+
+func (f *fakeQueue) PublishState(domain string, timerID uuid.UUID, state *messaging.State) error {
+	f.states = append(f.states, state)
+	return nil
+}
+
+func (f *fakeQueue) PublishTrigger(domain string, timerID uuid.UUID, trigger *messaging.Trigger) error {
+	f.triggers = append(f.triggers, trigger)
+	return nil
+}
+
+func (f *fakeQueue) PublishCreates(domain string, creates []*messaging.Create) []error {
+	f.batches++
+	f.creates = append(f.creates, creates...)
+	return make([]error, len(creates))
+}
+
+func (f *fakeQueue) PublishDelete(domain string, timerID uuid.UUID) error {
+	f.deletes = append(f.deletes, timerID)
+	return nil
+}
+
+func (f *fakeQueue) PublishDeletes(domain string, timerIDs []uuid.UUID) []error {
+	f.deletes = append(f.deletes, timerIDs...)
+	return make([]error, len(timerIDs))
+}
+
+func (f *fakeQueue) PublishCreate(domain string, timerID uuid.UUID, create *messaging.Create) error {
+	f.creates = append(f.creates, create)
+	return nil
+}
+
+// getTestRequest returns a request to create a timer with the labels, requests with the same labels are the same.
+func getTestRequest(labels map[string]string, idempotencyKey string) *services.CreateTimerRequest {
+	create := getTestCreate(labels)
+	create.Schedule.StartTime = timestamppb.New(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+	return &services.CreateTimerRequest{
+		Task:           create.Task,
+		Schedule:       create.Schedule,
+		Labels:         labels,
+		IdempotencyKey: idempotencyKey,
+	}
+}
+
+// getTestCreate returns a timer of testDomain with the labels.
+func getTestCreate(labels map[string]string) *messaging.Create {
+	return &messaging.Create{
+		Task: &common.Task{
+			Task: &common.Task_Http{Http: &common.HTTP{Url: "http://localhost:1"}},
+		},
+		Schedule: &common.Schedule{
+			Spec:      &common.Schedule_Cron{Cron: "@every 1h"},
+			StartTime: timestamppb.Now(),
+		},
+		Meta: &common.Meta{
+			CreateTime: timestamppb.Now(),
+			Domain:     testDomain,
+			TimerUuid:  testUUID,
+		},
+		Labels: labels,
+	}
+}
+
+func TestUpdateTimer(t *testing.T) {
+	var tests = []struct {
+		name string
+		// setup fills in the registry and timers index.
+		setup     func(f *fakeDB)
+		code      codes.Code
+		seqNo     int // the SeqNo of the registration after the update.
+		published bool
+	}{
+		{
+			name: "registered",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(nil), SeqNo: 3}
+				// the timers index lags behind, the update applies to the registered timer.
+				f.timers[testUUID] = &db.Timer{Create: getTestCreate(map[string]string{"stale": "true"})}
+			},
+			code:      codes.OK,
+			seqNo:     4,
+			published: true,
+		},
+		{
+			name: "created before the registry",
+			setup: func(f *fakeDB) {
+				f.timers[testUUID] = &db.Timer{Create: getTestCreate(nil)}
+			},
+			code:      codes.OK,
+			seqNo:     1,
+			published: true,
+		},
+		{
+			name: "deleted",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = nil
+				// the timers index hasn't removed it yet, it isn't resurrected.
+				f.timers[testUUID] = &db.Timer{Create: getTestCreate(nil)}
+			},
+			code: codes.NotFound,
+		},
+		{
+			name:  "not found",
+			setup: func(f *fakeDB) {},
+			code:  codes.NotFound,
+		},
+		{
+			name: "concurrent update",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(nil)}
+				f.afterGet = func() {
+					f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(map[string]string{"other": "update"}), SeqNo: 1}
+				}
+			},
+			code:  codes.Aborted,
+			seqNo: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fdb, fqueue := newFakeDB(), &fakeQueue{}
+		test.setup(fdb)
+		s := NewServer(fqueue, nil, fdb)
+
+		labels := map[string]string{"team": "billing"}
+		_, err := s.UpdateTimer(util.SetClientID(context.Background(), testDomain), &services.UpdateTimerRequest{
+			TimerUuid:  testUUID,
+			Labels:     labels,
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels"}},
+		})
+		if code := status.Code(err); code != test.code {
+			t.Errorf("case: %v. expected code %v, got %v", test.name, test.code, err)
+			continue
+		}
+
+		if !test.published {
+			if len(fqueue.creates) != 0 {
+				t.Errorf("case: %v. unexpected publish %v", test.name, fqueue.creates)
+			}
+			if registered := fdb.registry[testUUID]; registered != nil && registered.SeqNo != test.seqNo {
+				t.Errorf("case: %v. expected registration %v, got %v", test.name, test.seqNo, registered.SeqNo)
+			}
+			continue
+		}
+
+		if len(fqueue.creates) != 1 || len(fqueue.creates[0].Labels) != 1 || fqueue.creates[0].Labels["team"] != "billing" {
+			t.Errorf("case: %v. unexpected publish %v", test.name, fqueue.creates)
+			continue
+		}
+
+		registered := fdb.registry[testUUID]
+		if registered.SeqNo != test.seqNo || !proto.Equal(registered.Create, fqueue.creates[0]) {
+			t.Errorf("case: %v. registry doesn't have the published update, got %v", test.name, registered)
+		}
+	}
+}
+
+func TestGetTimers(t *testing.T) {
+	finished := getTestCreate(nil)
+	finished.Schedule.MaxExecutions = 2
+
+	var tests = []struct {
+		name   string
+		timer  *db.Timer
+		state  services.TimerState
+		isNext bool
+	}{
+		{"active", &db.Timer{Create: getTestCreate(nil)}, services.TimerState_TIMER_STATE_ACTIVE, true},
+		{"paused", &db.Timer{Create: getTestCreate(nil), Paused: true}, services.TimerState_TIMER_STATE_PAUSED, false},
+		// finished timers are indexed until their tombstone is, they aren't active.
+		{"finished", &db.Timer{Create: finished}, services.TimerState_TIMER_STATE_FINISHED, false},
+	}
+
+	for _, test := range tests {
+		fdb := newFakeDB()
+		fdb.progresses = map[string]*messaging.Progress{testUUID: {CompletedExecutions: 2}}
+		s := &server{db: fdb}
+
+		timers, err := s.getTimers(context.Background(), []*db.Timer{test.timer})
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if timers[0].State != test.state || (timers[0].NextFireTime != nil) != test.isNext {
+			t.Errorf("case: %v. expected state %v, got %v with next fire %v", test.name, test.state, timers[0].State, timers[0].NextFireTime)
+		}
+	}
+}
+
+func TestDeleteTimer(t *testing.T) {
+	var tests = []struct {
+		name  string
+		setup func(f *fakeDB)
+		code  codes.Code
+	}{
+		{
+			// the timers index doesn't have timers until the elastic consumer indexes them.
+			name: "registered",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(nil)}
+			},
+			code: codes.OK,
+		},
+		{
+			name: "created before the registry",
+			setup: func(f *fakeDB) {
+				f.timers[testUUID] = &db.Timer{Create: getTestCreate(nil)}
+			},
+			code: codes.OK,
+		},
+		{
+			name: "deleted",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = nil
+				f.timers[testUUID] = &db.Timer{Create: getTestCreate(nil)}
+			},
+			code: codes.NotFound,
+		},
+		{
+			// timers of other domains aren't in the domain's registry or index.
+			name:  "not found",
+			setup: func(f *fakeDB) {},
+			code:  codes.NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fdb, fqueue := newFakeDB(), &fakeQueue{}
+		test.setup(fdb)
+		s := NewServer(fqueue, nil, fdb)
+
+		_, err := s.DeleteTimer(util.SetClientID(context.Background(), testDomain), &services.DeleteTimerRequest{TimerUuid: testUUID})
+		if code := status.Code(err); code != test.code {
+			t.Errorf("case: %v. expected code %v, got %v", test.name, test.code, err)
+			continue
+		}
+
+		deleted := test.code == codes.OK
+		if (len(fqueue.deletes) == 1) != deleted {
+			t.Errorf("case: %v. unexpected deletes %v", test.name, fqueue.deletes)
+		}
+		if registered, ok := fdb.registry[testUUID]; deleted && (!ok || registered != nil) {
+			t.Errorf("case: %v. expected timer to be unregistered", test.name)
+		}
+	}
+}
+
+func TestBatchDeleteTimers(t *testing.T) {
+	const (
+		registered  = "0f3c2d1e-5b4a-4c3d-8e2f-1a0b9c8d7e6f"
+		indexed     = "1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d"
+		deleted     = "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e"
+		otherDomain = "3c4d5e6f-7a8b-4c9d-8e1f-2a3b4c5d6e7f"
+		uppercase   = "4D5E6F7A-8B9C-4D0E-9F2A-3B4C5D6E7F8A"
+		lowercase   = "4d5e6f7a-8b9c-4d0e-9f2a-3b4c5d6e7f8a"
+	)
+
+	fdb, fqueue := newFakeDB(), &fakeQueue{}
+	fdb.registry[registered] = &db.RegisteredTimer{Create: getTestCreate(nil)}
+	fdb.registry[deleted] = nil
+	fdb.timers[indexed] = &db.Timer{Create: getTestCreate(nil)}
+	// the lagging index still has the deleted timer, the registry decides.
+	fdb.timers[deleted] = &db.Timer{Create: getTestCreate(nil)}
+	fdb.timers[lowercase] = &db.Timer{Create: getTestCreate(nil)}
+	s := NewServer(fqueue, nil, fdb)
+
+	res, err := s.BatchDeleteTimers(util.SetClientID(context.Background(), testDomain), &services.BatchDeleteTimersRequest{
+		TimerUuids: []string{registered, indexed, deleted, otherDomain, uppercase, "invalid"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []codes.Code{codes.OK, codes.OK, codes.NotFound, codes.NotFound, codes.OK, codes.InvalidArgument}
+	for i, result := range res.Results {
+		if code := codes.Code(result.Error.GetCode()); code != expect[i] {
+			t.Errorf("case: %v. expected code %v, got %v", result.TimerUuid, expect[i], result.Error)
+		}
+	}
+
+	var deletes []string
+	for _, id := range fqueue.deletes {
+		deletes = append(deletes, id.String())
+	}
+	if len(deletes) != 3 || deletes[0] != registered || deletes[1] != indexed || deletes[2] != lowercase {
+		t.Errorf("unexpected deletes %v", deletes)
+	}
+
+	for _, id := range []string{registered, indexed, lowercase} {
+		if registration, ok := fdb.registry[id]; !ok || registration != nil {
+			t.Errorf("case: %v. expected timer to be unregistered", id)
+		}
+	}
+}
+
+func TestPauseResumeTimer(t *testing.T) {
+	var tests = []struct {
+		name  string
+		setup func(f *fakeDB)
+		code  codes.Code
+	}{
+		{
+			name: "registered",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(nil)}
+			},
+			code: codes.OK,
+		},
+		{
+			name: "deleted",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = nil
+			},
+			code: codes.NotFound,
+		},
+		{
+			name:  "not found",
+			setup: func(f *fakeDB) {},
+			code:  codes.NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fdb, fqueue := newFakeDB(), &fakeQueue{}
+		test.setup(fdb)
+		s := NewServer(fqueue, nil, fdb)
+		ctx := util.SetClientID(context.Background(), testDomain)
+
+		_, pauseErr := s.PauseTimer(ctx, &services.PauseTimerRequest{TimerUuid: testUUID})
+		_, resumeErr := s.ResumeTimer(ctx, &services.ResumeTimerRequest{TimerUuid: testUUID})
+		if status.Code(pauseErr) != test.code || status.Code(resumeErr) != test.code {
+			t.Errorf("case: %v. expected code %v, got %v and %v", test.name, test.code, pauseErr, resumeErr)
+			continue
+		}
+
+		if test.code != codes.OK {
+			if len(fqueue.states) != 0 {
+				t.Errorf("case: %v. unexpected states %v", test.name, fqueue.states)
+			}
+			continue
+		}
+
+		if len(fqueue.states) != 2 || !fqueue.states[0].Paused || fqueue.states[1].Paused {
+			t.Errorf("case: %v. unexpected states %v", test.name, fqueue.states)
+		}
+	}
+}
+
+func TestTriggerTimer(t *testing.T) {
+	var tests = []struct {
+		name   string
+		domain string
+		setup  func(f *fakeDB)
+		code   codes.Code
+	}{
+		{
+			name:   "registered",
+			domain: testDomain,
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(nil)}
+			},
+			code: codes.OK,
+		},
+		{
+			name:   "deleted",
+			domain: testDomain,
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = nil
+			},
+			code: codes.NotFound,
+		},
+		{
+			name:   "not found",
+			domain: testDomain,
+			setup:  func(f *fakeDB) {},
+			code:   codes.NotFound,
+		},
+		{
+			name:   "other domain",
+			domain: "other",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(nil)}
+			},
+			code: codes.NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fdb, fqueue := newFakeDB(), &fakeQueue{}
+		test.setup(fdb)
+		s := NewServer(fqueue, nil, fdb)
+
+		res, err := s.TriggerTimer(util.SetClientID(context.Background(), test.domain), &services.TriggerTimerRequest{TimerUuid: testUUID})
+		if status.Code(err) != test.code {
+			t.Errorf("case: %v. expected code %v, got %v", test.name, test.code, err)
+			continue
+		}
+
+		if test.code != codes.OK {
+			if len(fqueue.triggers) != 0 {
+				t.Errorf("case: %v. unexpected triggers %v", test.name, fqueue.triggers)
+			}
+			continue
+		}
+
+		if len(fqueue.triggers) != 1 || fqueue.triggers[0].TriggerId != res.TriggerId {
+			t.Errorf("case: %v. expected trigger %v, got %v", test.name, res.TriggerId, fqueue.triggers)
+		}
+	}
+}
+
+func TestBatchCreateTimers(t *testing.T) {
+	fdb, fqueue := newFakeDB(), &fakeQueue{}
+	s := NewServer(fqueue, nil, fdb)
+
+	// an earlier batch reserved the key but failed before publishing its timer.
+	unpublished := getTestCreate(map[string]string{"attempt": "first"})
+	unpublished.Meta.TimerUuid = "0f3c2d1e-5b4a-4c3d-8e2f-1a0b9c8d7e6f"
+	unpublishedBytes, err := proto.Marshal(unpublished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried := getTestRequest(map[string]string{"attempt": "second"}, "retried")
+	hash, err := getIdempotentRequestHash(retried)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdb.idempotency["retried"] = &elastic.Idempotency{RequestHash: hash, TimerUUID: unpublished.Meta.TimerUuid, Create: unpublishedBytes}
+
+	res, err := s.BatchCreateTimers(util.SetClientID(context.Background(), testDomain), &services.BatchCreateTimersRequest{
+		Timers: []*services.CreateTimerRequest{
+			getTestRequest(nil, ""),
+			getTestRequest(nil, "first"),
+			// timers with the same key in a batch are one timer, like retries.
+			getTestRequest(nil, "first"),
+			getTestRequest(map[string]string{"team": "billing"}, "first"),
+			getTestRequest(nil, "invalid key"),
+			{},
+			retried,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []codes.Code{codes.OK, codes.OK, codes.OK, codes.AlreadyExists, codes.InvalidArgument, codes.InvalidArgument, codes.OK}
+	for i, result := range res.Results {
+		if code := codes.Code(result.Error.GetCode()); code != expect[i] {
+			t.Errorf("case: %v. expected code %v, got %v", i, expect[i], result.Error)
+		}
+		if (result.TimerUuid != "") != (expect[i] == codes.OK) {
+			t.Errorf("case: %v. unexpected timer uuid %q", i, result.TimerUuid)
+		}
+	}
+
+	if res.Results[1].TimerUuid != res.Results[2].TimerUuid || res.Results[0].TimerUuid == res.Results[1].TimerUuid {
+		t.Errorf("expected timers with the same key to be the same timer, got %v", res.Results)
+	}
+
+	if res.Results[6].TimerUuid != unpublished.Meta.TimerUuid {
+		t.Errorf("expected the timer the key was reserved with, got %v", res.Results[6].TimerUuid)
+	}
+
+	// keys are reserved first, then every timer is published together and registered.
+	if fqueue.batches != 1 || len(fqueue.creates) != 3 {
+		t.Fatalf("expected 3 timers published in 1 batch, got %v in %v", len(fqueue.creates), fqueue.batches)
+	}
+	for _, create := range fqueue.creates {
+		if _, ok := fdb.registry[create.Meta.TimerUuid]; !ok {
+			t.Errorf("expected timer %v to be registered", create.Meta.TimerUuid)
+		}
+	}
+	if !proto.Equal(fqueue.creates[2], unpublished) {
+		t.Errorf("expected the reserved timer to be published, got %v", fqueue.creates[2])
+	}
+
+	for _, key := range []string{"first", "retried"} {
+		if !fdb.idempotency[key].Published {
+			t.Errorf("case: %v. expected the key to be published", key)
+		}
+	}
+}
+
+func TestCreateIdempotently(t *testing.T) {
+	unpublished := getTestCreate(map[string]string{"attempt": "first"})
+	unpublishedBytes, err := proto.Marshal(unpublished)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		// existing is the record of the key before the request, if it was reserved.
+		existing *elastic.Idempotency
+		hash     string
+		code     codes.Code
+		uuid     string
+		// published is the labels of the timer published, nil if none was.
+		published map[string]string
+	}{
+		{
+			name:      "new key",
+			hash:      "hash",
+			code:      codes.OK,
+			published: map[string]string{"attempt": "second"},
+		},
+		{
+			name:     "same request",
+			existing: &elastic.Idempotency{RequestHash: "hash", TimerUUID: testUUID, Create: unpublishedBytes, Published: true},
+			hash:     "hash",
+			code:     codes.OK,
+			uuid:     testUUID,
+		},
+		{
+			name:     "different request",
+			existing: &elastic.Idempotency{RequestHash: "other", TimerUUID: testUUID, Create: unpublishedBytes, Published: true},
+			hash:     "hash",
+			code:     codes.AlreadyExists,
+		},
+		{
+			// the first request failed after reserving the key, its timer is published rather than the retry's.
+			name:      "reserved but not published",
+			existing:  &elastic.Idempotency{RequestHash: "hash", TimerUUID: testUUID, Create: unpublishedBytes},
+			hash:      "hash",
+			code:      codes.OK,
+			uuid:      testUUID,
+			published: map[string]string{"attempt": "first"},
+		},
+	}
+
+	for _, test := range tests {
+		fdb, fqueue := newFakeDB(), &fakeQueue{}
+		if test.existing != nil {
+			fdb.idempotency["key"] = test.existing
+		}
+		s := &server{db: fdb, queue: fqueue}
+
+		retry := getTestCreate(map[string]string{"attempt": "second"})
+		retry.Meta.TimerUuid = "0f3c2d1e-5b4a-4c3d-8e2f-1a0b9c8d7e6f"
+		res, err := s.createIdempotently(context.Background(), testDomain, "key", test.hash, retry)
+		if code := status.Code(err); code != test.code {
+			t.Errorf("case: %v. expected code %v, got %v", test.name, test.code, err)
+			continue
+		}
+		if err != nil {
+			if len(fqueue.creates) != 0 {
+				t.Errorf("case: %v. unexpected publish %v", test.name, fqueue.creates)
+			}
+			continue
+		}
+
+		uuid := test.uuid
+		if uuid == "" {
+			uuid = retry.Meta.TimerUuid
+		}
+		if res.TimerUuid != uuid {
+			t.Errorf("case: %v. expected timer %v, got %v", test.name, uuid, res.TimerUuid)
+		}
+
+		if test.published == nil {
+			if len(fqueue.creates) != 0 {
+				t.Errorf("case: %v. unexpected publish %v", test.name, fqueue.creates)
+			}
+			continue
+		}
+
+		if len(fqueue.creates) != 1 || fqueue.creates[0].Labels["attempt"] != test.published["attempt"] || fqueue.creates[0].Meta.TimerUuid != uuid {
+			t.Errorf("case: %v. unexpected publish %v", test.name, fqueue.creates)
+		}
+		if !fdb.idempotency["key"].Published {
+			t.Errorf("case: %v. expected the key to be published", test.name)
+		}
+		if _, ok := fdb.registry[uuid]; !ok {
+			t.Errorf("case: %v. expected the timer to be registered", test.name)
+		}
+	}
+}
+
+func TestPutCalendar(t *testing.T) {
+	const (
+		refers    = "0f3c2d1e-5b4a-4c3d-8e2f-1a0b9c8d7e6f"
+		other     = "5d0c8b7a-2e1f-4a3b-9c8d-7e6f5a4b3c2d"
+		deleted   = "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+		holidays  = "holidays"
+		blackouts = "blackouts"
+	)
+
+	fdb, fqueue := newFakeDB(), &fakeQueue{}
+	s := &server{db: fdb, queue: fqueue}
+
+	create := getTestCreate(nil)
+	create.Meta.TimerUuid = refers
+	create.Meta.UpdateTime = timestamppb.New(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+	create.ResetProgress = true
+	create.Schedule.Calendars = []string{holidays, blackouts}
+	create.Calendars = []*common.Calendar{{Name: holidays, Dates: []string{"2020-12-25"}}, {Name: blackouts}}
+	fdb.registry[refers] = &db.RegisteredTimer{Create: create, SeqNo: 1}
+
+	otherCreate := getTestCreate(nil)
+	otherCreate.Meta.TimerUuid = other
+	otherCreate.Schedule.Calendars = []string{blackouts}
+	otherCreate.Calendars = []*common.Calendar{{Name: blackouts}}
+	fdb.registry[other] = &db.RegisteredTimer{Create: otherCreate}
+	fdb.registry[deleted] = nil
+
+	calendar := &common.Calendar{Name: holidays, Dates: []string{"2020-12-25", "2021-01-01"}}
+	ctx := util.SetClientID(context.Background(), testDomain)
+	if _, err := s.PutCalendar(ctx, &services.PutCalendarRequest{Calendar: calendar}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fqueue.creates) != 1 || fqueue.creates[0].Meta.TimerUuid != refers {
+		t.Fatalf("expected only timer %v to be republished, got %v", refers, fqueue.creates)
+	}
+
+	republished := fqueue.creates[0]
+	if !proto.Equal(republished.Calendars[0], calendar) || !proto.Equal(republished.Calendars[1], create.Calendars[1]) {
+		t.Errorf("expected calendars %v and %v, got %v", calendar, create.Calendars[1], republished.Calendars)
+	}
+	if !proto.Equal(republished.Meta, create.Meta) || !republished.ResetProgress {
+		t.Errorf("expected meta %v to be kept, got %v", create.Meta, republished.Meta)
+	}
+	if r := fdb.registry[refers]; r.SeqNo != 2 || !proto.Equal(r.Create, republished) {
+		t.Errorf("expected the registration to be updated, got %v", r)
+	}
+	if !proto.Equal(create.Calendars[0], &common.Calendar{Name: holidays, Dates: []string{"2020-12-25"}}) {
+		t.Errorf("expected the registered create to be left as it was, got %v", create.Calendars[0])
+	}
+}
+
+func TestDeleteCalendar(t *testing.T) {
+	const holidays = "holidays"
+
+	var tests = []struct {
+		name  string
+		setup func(f *fakeDB)
+		code  codes.Code
+	}{
+		{
+			name: "used",
+			setup: func(f *fakeDB) {
+				create := getTestCreate(nil)
+				create.Schedule.Calendars = []string{holidays}
+				create.Calendars = []*common.Calendar{{Name: holidays}}
+				f.registry[testUUID] = &db.RegisteredTimer{Create: create}
+			},
+			code: codes.FailedPrecondition,
+		},
+		{
+			// the search can find timers that no longer refer to it, or were deleted.
+			name: "unused",
+			setup: func(f *fakeDB) {
+				f.registry[testUUID] = &db.RegisteredTimer{Create: getTestCreate(nil)}
+				f.registry["9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"] = nil
+			},
+			code: codes.OK,
+		},
+	}
+
+	for _, test := range tests {
+		fdb := newFakeDB()
+		test.setup(fdb)
+		s := NewServer(&fakeQueue{}, nil, fdb)
+
+		_, err := s.DeleteCalendar(util.SetClientID(context.Background(), testDomain), &services.DeleteCalendarRequest{Name: holidays})
+		if status.Code(err) != test.code {
+			t.Errorf("case: %v. expected code %v, got %v", test.name, test.code, err)
+		}
+
+		if deleted := len(fdb.deletedCalendars) != 0; deleted != (test.code == codes.OK) {
+			t.Errorf("case: %v. expected deleted %v, got %v", test.name, test.code == codes.OK, fdb.deletedCalendars)
+		}
+	}
+}
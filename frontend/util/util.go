@@ -3,8 +3,12 @@ package util
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"path"
 	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 type key int
@@ -53,3 +57,51 @@ func ShiftPath(p string) (head, tail string) {
 	}
 	return p[1:i], p[i:]
 }
+
+// ApplyFieldMask sets the fields of dst at paths to their values in src, or clears them if they're unset in src.
+// Paths are dot separated field names, like "schedule.cron". dst isn't changed if a path is invalid.
+func ApplyFieldMask(dst, src proto.Message, paths []string) error {
+	for _, p := range paths {
+		if err := checkPath(dst.ProtoReflect().Descriptor(), p); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range paths {
+		d, s := dst.ProtoReflect(), src.ProtoReflect()
+		names := strings.Split(p, ".")
+		for _, name := range names[:len(names)-1] {
+			fd := d.Descriptor().Fields().ByName(protoreflect.Name(name))
+			d, s = d.Mutable(fd).Message(), s.Get(fd).Message()
+		}
+
+		fd := d.Descriptor().Fields().ByName(protoreflect.Name(names[len(names)-1]))
+		if s.Has(fd) {
+			d.Set(fd, s.Get(fd))
+		} else {
+			d.Clear(fd)
+		}
+	}
+	return nil
+}
+
+// checkPath returns an error if p doesn't refer to a field of md, through singular message fields.
+func checkPath(md protoreflect.MessageDescriptor, p string) error {
+	names := strings.Split(p, ".")
+	for i, name := range names {
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return fmt.Errorf("unknown field %v in path %v", name, p)
+		}
+
+		if i == len(names)-1 {
+			break
+		}
+
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("field %v in path %v isn't a message", name, p)
+		}
+		md = fd.Message()
+	}
+	return nil
+}
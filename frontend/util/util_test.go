@@ -4,6 +4,10 @@ import (
 	"encoding/base64"
 	"net/http"
 	"testing"
+
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/.gen/protos/services"
+	"google.golang.org/protobuf/proto"
 )
 
 var shiftPathTests = []struct {
@@ -55,3 +59,93 @@ func TestParseBasicAuth(t *testing.T) {
 		}
 	}
 }
+
+var applyFieldMaskTests = []struct {
+	name     string
+	paths    []string
+	src      *services.CreateTimerRequest
+	expected *services.CreateTimerRequest
+	err      bool
+}{
+	{
+		name:  "leaf",
+		paths: []string{"task.http.url"},
+		src: &services.CreateTimerRequest{Task: &common.Task{Task: &common.Task_Http{Http: &common.HTTP{
+			Url:    "http://new.com",
+			Method: common.Method_POST,
+		}}}},
+		expected: &services.CreateTimerRequest{
+			Task: &common.Task{Task: &common.Task_Http{Http: &common.HTTP{
+				Url:     "http://new.com",
+				Headers: map[string]string{"a": "b"},
+			}}},
+			Schedule: &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@hourly"}, MaxExecutions: 3},
+		},
+	},
+	{
+		name:  "oneof",
+		paths: []string{"schedule.cron"},
+		src:   &services.CreateTimerRequest{Schedule: &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@daily"}}},
+		expected: &services.CreateTimerRequest{
+			Task: &common.Task{Task: &common.Task_Http{Http: &common.HTTP{
+				Url:     "http://old.com",
+				Headers: map[string]string{"a": "b"},
+			}}},
+			Schedule: &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@daily"}, MaxExecutions: 3},
+		},
+	},
+	{
+		name:  "clear",
+		paths: []string{"schedule.max_executions", "task.http.headers"},
+		src:   &services.CreateTimerRequest{},
+		expected: &services.CreateTimerRequest{
+			Task:     &common.Task{Task: &common.Task_Http{Http: &common.HTTP{Url: "http://old.com"}}},
+			Schedule: &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@hourly"}},
+		},
+	},
+	{
+		name:  "message",
+		paths: []string{"schedule"},
+		src:   &services.CreateTimerRequest{Schedule: &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@daily"}}},
+		expected: &services.CreateTimerRequest{
+			Task: &common.Task{Task: &common.Task_Http{Http: &common.HTTP{
+				Url:     "http://old.com",
+				Headers: map[string]string{"a": "b"},
+			}}},
+			Schedule: &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@daily"}},
+		},
+	},
+	{name: "unknown field", paths: []string{"task.http.uri"}, src: &services.CreateTimerRequest{}, err: true},
+	{name: "through scalar", paths: []string{"schedule.cron.spec"}, src: &services.CreateTimerRequest{}, err: true},
+	{name: "through map", paths: []string{"task.http.headers.a"}, src: &services.CreateTimerRequest{}, err: true},
+}
+
+func TestApplyFieldMask(t *testing.T) {
+	for _, test := range applyFieldMaskTests {
+		dst := &services.CreateTimerRequest{
+			Task: &common.Task{Task: &common.Task_Http{Http: &common.HTTP{
+				Url:     "http://old.com",
+				Headers: map[string]string{"a": "b"},
+			}}},
+			Schedule: &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@hourly"}, MaxExecutions: 3},
+		}
+		before := proto.Clone(dst)
+
+		err := ApplyFieldMask(dst, test.src, test.paths)
+		if test.err {
+			if err == nil {
+				t.Errorf("case: %v. expected error", test.name)
+			}
+			if !proto.Equal(dst, before) {
+				t.Errorf("case: %v. dst changed on error: %v", test.name, dst)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+		} else if !proto.Equal(dst, test.expected) {
+			t.Errorf("case: %v. got %v, expected %v", test.name, dst, test.expected)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package keys encodes the messaging.Keys of records in the create and execute topics.
+//
+// Keys were first written as plain proto encoded messaging.Keys, now called LegacyVersion.
+// Versioned keys start with a zero byte, which can't start a proto encoded message, followed by their version.
+// Decode reads keys of every version, so records written before keys were versioned are still read,
+// and deletes are written in each of Versions so that compaction removes records of any version.
+package keys
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"google.golang.org/protobuf/proto"
+)
+
+// versions of the key encoding.
+const (
+	// LegacyVersion is a plain proto encoded messaging.Key, without a version prefix.
+	LegacyVersion byte = 0
+	// Version is the version Encode writes, a proto encoded messaging.Key after the prefix.
+	Version byte = 1
+)
+
+// versionMarker starts a versioned key, proto field numbers start at 1 so it doesn't start a legacy key.
+const versionMarker byte = 0
+
+// Versions are the versions keys may be written in, records of a timer have to be deleted in each.
+var Versions = []byte{LegacyVersion, Version}
+
+var (
+	// ErrEmptyKey is returned when decoding an empty key.
+	ErrEmptyKey = errors.New("empty key")
+	// ErrUnknownVersion is returned when decoding a key of a version this build doesn't know.
+	ErrUnknownVersion = errors.New("unknown key version")
+)
+
+// Encode returns key encoded in the current Version.
+func Encode(key *messaging.Key) ([]byte, error) {
+	return EncodeVersion(key, Version)
+}
+
+// EncodeVersion returns key encoded in the given version.
+func EncodeVersion(key *messaging.Key, version byte) ([]byte, error) {
+	// deterministic, so the same key is always the same bytes, which compaction relies on.
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch version {
+	case LegacyVersion:
+		return b, nil
+	case Version:
+		return append([]byte{versionMarker, Version}, b...), nil
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownVersion, version)
+	}
+}
+
+// Decode returns the key encoded in b, in any version.
+func Decode(b []byte) (*messaging.Key, error) {
+	if len(b) == 0 {
+		return nil, ErrEmptyKey
+	}
+
+	payload := b
+	if b[0] == versionMarker {
+		if len(b) < 2 || b[1] != Version {
+			return nil, ErrUnknownVersion
+		}
+		payload = b[2:]
+	}
+
+	var key messaging.Key
+	if err := proto.Unmarshal(payload, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// WithKind returns the key of the record of the given kind of the same timer, encoded in the given version.
+// Only trigger keys keep the trigger id.
+func WithKind(key *messaging.Key, kind messaging.KeyKind, version byte) ([]byte, error) {
+	kindKey := proto.Clone(key).(*messaging.Key)
+	kindKey.Kind = kind
+	if kind != messaging.KeyKind_KEY_KIND_TRIGGER {
+		kindKey.TriggerId = ""
+	}
+	return EncodeVersion(kindKey, version)
+}
+
+// ID returns the id of the timer a record of any kind and version belongs to, the current version of its create key.
+// Runtimers keep the state of timers by their id, so records written in every version apply to the same timer.
+func ID(key *messaging.Key) (string, error) {
+	b, err := WithKind(key, messaging.KeyKind_KEY_KIND_CREATE, Version)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,182 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecode(t *testing.T) {
+	create := &messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"}
+	state := &messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Kind: messaging.KeyKind_KEY_KIND_STATE}
+
+	// keys already in topics were written with proto.Marshal.
+	legacyCreate, err := proto.Marshal(create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyState, err := proto.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := Encode(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name   string
+		b      []byte
+		expect *messaging.Key
+		err    error
+	}{
+		{"legacy create", legacyCreate, create, nil},
+		{"legacy state", legacyState, state, nil},
+		{"current", current, state, nil},
+		{"empty", nil, nil, ErrEmptyKey},
+		{"unknown version", append([]byte{versionMarker, Version + 1}, legacyState...), nil, ErrUnknownVersion},
+		{"only marker", []byte{versionMarker}, nil, ErrUnknownVersion},
+	}
+
+	for _, test := range tests {
+		key, err := Decode(test.b)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("case: %v. expected error %v, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+		} else if !proto.Equal(key, test.expect) {
+			t.Errorf("case: %v. expected %v, got %v", test.name, test.expect, key)
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	key := &messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Kind: messaging.KeyKind_KEY_KIND_TRIGGER}
+	legacy, err := proto.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name    string
+		version byte
+		expect  []byte
+		err     error
+	}{
+		// deletes of timers created before versioning must match their keys exactly.
+		{"legacy", LegacyVersion, legacy, nil},
+		{"current", Version, append([]byte{versionMarker, Version}, legacy...), nil},
+		{"unknown", Version + 1, nil, ErrUnknownVersion},
+	}
+
+	for _, test := range tests {
+		b, err := EncodeVersion(key, test.version)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("case: %v. expected error %v, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+		} else if !bytes.Equal(b, test.expect) {
+			t.Errorf("case: %v. expected %x, got %x", test.name, test.expect, b)
+		}
+	}
+}
+
+func TestWithKind(t *testing.T) {
+	create := &messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"}
+
+	for _, version := range Versions {
+		b, err := WithKind(create, messaging.KeyKind_KEY_KIND_STATE, version)
+		if err != nil {
+			t.Errorf("case: version %v. unexpected error: %v", version, err)
+			continue
+		}
+
+		key, err := Decode(b)
+		if err != nil {
+			t.Errorf("case: version %v. unexpected error: %v", version, err)
+		} else if key.Kind != messaging.KeyKind_KEY_KIND_STATE || key.Domain != create.Domain || key.TimerUUID != create.TimerUUID {
+			t.Errorf("case: version %v. unexpected key %v", version, key)
+		}
+	}
+
+	if create.Kind != messaging.KeyKind_KEY_KIND_CREATE {
+		t.Errorf("WithKind modified its key")
+	}
+
+	trigger := &messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Kind: messaging.KeyKind_KEY_KIND_TRIGGER, TriggerId: "4f6d0a3c-2b1e-4e7a-9c2d-1a5b7e9f3c80"}
+	var tests = []struct {
+		name      string
+		kind      messaging.KeyKind
+		triggerID string
+	}{
+		{"trigger", messaging.KeyKind_KEY_KIND_TRIGGER, trigger.TriggerId},
+		// records of other kinds are per timer.
+		{"state", messaging.KeyKind_KEY_KIND_STATE, ""},
+	}
+
+	for _, test := range tests {
+		b, err := WithKind(trigger, test.kind, Version)
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+			continue
+		}
+
+		key, err := Decode(b)
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+		} else if key.TriggerId != test.triggerID {
+			t.Errorf("case: %v. expected trigger id %q, got %q", test.name, test.triggerID, key.TriggerId)
+		}
+	}
+}
+
+func TestID(t *testing.T) {
+	legacyState, err := proto.Marshal(&messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Kind: messaging.KeyKind_KEY_KIND_STATE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentTrigger, err := Encode(&messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Kind: messaging.KeyKind_KEY_KIND_TRIGGER, TriggerId: "4f6d0a3c-2b1e-4e7a-9c2d-1a5b7e9f3c80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, b := range [][]byte{legacyState, currentTrigger} {
+		key, err := Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := ID(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if ids[0] != ids[1] {
+		t.Errorf("expected records of the same timer to have the same id, got %x and %x", ids[0], ids[1])
+	}
+
+	other, err := ID(&messaging.Key{Domain: "other", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == ids[0] {
+		t.Errorf("expected timers of different domains to have different ids")
+	}
+}
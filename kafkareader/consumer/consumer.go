@@ -5,6 +5,7 @@ import (
 
 	"github.com/Shopify/sarama"
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -65,20 +66,52 @@ func (c *Consumer) consumeCreates(claim sarama.ConsumerGroupClaim) {
 			continue
 		}
 
-		fmt.Println("-- ID:", string(msg.Key))
+		key, err := keys.Decode(msg.Key)
+		if err != nil {
+			fmt.Printf("-- UNREADABLE KEY %x: %v\n", msg.Key, err)
+			continue
+		}
+
+		fmt.Println("-- ID:", key.TimerUUID)
+		fmt.Println("-- DOMAIN:", key.Domain)
 
 		if msg.Value == nil {
 			fmt.Println("-- DELETE")
+			continue
+		}
+
+		switch key.Kind {
+		case messaging.KeyKind_KEY_KIND_STATE:
+			var val messaging.State
+			err := proto.Unmarshal(msg.Value, &val)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			fmt.Println("-- STATE:", &val)
+			continue
+
+		case messaging.KeyKind_KEY_KIND_TRIGGER:
+			var val messaging.Trigger
+			err := proto.Unmarshal(msg.Value, &val)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			fmt.Println("-- TRIGGER:", &val)
+			continue
 		}
 
 		var val messaging.Create
-		err := proto.Unmarshal(msg.Value, &val)
+		err = proto.Unmarshal(msg.Value, &val)
 		if err != nil {
 			fmt.Println(err)
 			continue
 		}
 
-		fmt.Println("-- VALUE:", val)
+		fmt.Println("-- VALUE:", &val)
 	}
 }
 
@@ -91,8 +124,12 @@ func (c *Consumer) consumeExecutes(claim sarama.ConsumerGroupClaim) {
 			continue
 		}
 
-		var key messaging.Key
-		err := proto.Unmarshal(msg.Key, &key)
+		key, err := keys.Decode(msg.Key)
+		if err != nil {
+			fmt.Printf("-- UNREADABLE KEY %x: %v\n", msg.Key, err)
+			continue
+		}
+
 		fmt.Println("-- ID:", key.TimerUUID)
 		fmt.Println("-- DOMAIN:", key.Domain)
 		if msg.Value == nil {
@@ -106,6 +143,6 @@ func (c *Consumer) consumeExecutes(claim sarama.ConsumerGroupClaim) {
 			continue
 		}
 
-		fmt.Println("-- VALUE:", val)
+		fmt.Println("-- VALUE:", &val)
 	}
 }
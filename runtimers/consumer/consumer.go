@@ -6,6 +6,7 @@ import (
 
 	"github.com/Shopify/sarama"
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
 
 	"github.com/nivista/steady/runtimers/coordinator"
 	"google.golang.org/protobuf/proto"
@@ -104,22 +105,81 @@ func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim saram
 			continue
 		}
 
-		pk := string(msg.Key)
-
-		if msg.Value == nil {
-			man.RemoveTimer(pk)
+		key, err := keys.Decode(msg.Key)
+		if err != nil {
+			fmt.Println("consume claim decode key:", err.Error())
 			session.MarkMessage(msg, "")
 			continue
 		}
 
-		var create messaging.Create
-		err := proto.Unmarshal(msg.Value, &create)
+		// timers are identified by their id, whatever kind or version this record is.
+		pk, err := keys.ID(key)
 		if err != nil {
-			fmt.Println("consume claim unmarshal timer:", err.Error())
+			fmt.Println("consume claim encode key:", err.Error())
 			continue
 		}
-
-		man.CreateTimer(pk, &create)
+		kind := key.Kind
+
+		switch kind {
+		case messaging.KeyKind_KEY_KIND_CREATE:
+			if msg.Value == nil {
+				man.RemoveTimer(pk)
+				break
+			}
+
+			var create messaging.Create
+			err := proto.Unmarshal(msg.Value, &create)
+			if err != nil {
+				fmt.Println("consume claim unmarshal timer:", err.Error())
+				continue
+			}
+
+			// a timer belongs to the domain it's keyed by.
+			if create.Meta.GetDomain() != key.Domain {
+				fmt.Printf("consume claim timer %v of domain %v keyed by domain %v\n", create.Meta.GetTimerUuid(), create.Meta.GetDomain(), key.Domain)
+				break
+			}
+
+			man.CreateTimer(pk, &create)
+
+		case messaging.KeyKind_KEY_KIND_STATE:
+			// state tombstones are sent alongside create tombstones, which remove the timer.
+			if msg.Value == nil {
+				break
+			}
+
+			var state messaging.State
+			err := proto.Unmarshal(msg.Value, &state)
+			if err != nil {
+				fmt.Println("consume claim unmarshal state:", err.Error())
+				continue
+			}
+
+			if state.Paused {
+				man.PauseTimer(pk)
+			} else {
+				man.ResumeTimer(pk)
+			}
+
+		case messaging.KeyKind_KEY_KIND_TRIGGER:
+			// triggers are tombstoned once they're executed.
+			if msg.Value == nil {
+				man.RemoveTrigger(pk, msg.Key)
+				break
+			}
+
+			var trigger messaging.Trigger
+			err := proto.Unmarshal(msg.Value, &trigger)
+			if err != nil {
+				fmt.Println("consume claim unmarshal trigger:", err.Error())
+				continue
+			}
+
+			man.TriggerTimer(pk, msg.Key, &trigger)
+
+		default:
+			fmt.Println("consume claim unknown key kind:", kind)
+		}
 
 		session.MarkMessage(msg, "")
 	}
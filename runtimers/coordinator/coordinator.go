@@ -10,15 +10,17 @@ import (
 // Maybe the coordinator should just hand out managers and we deal w/ repartitions in the consumer.
 type Coordinator struct {
 	producer                  sarama.AsyncProducer
+	taskProducer              sarama.AsyncProducer // publishes the messages of kafka publish tasks.
 	db                        db.Client
 	managers                  map[int]*Manager
 	createTopic, executeTopic string
 	clock                     clockwork.Clock
 }
 
-func NewCoordinator(producer sarama.AsyncProducer, db db.Client, createTopic, executeTopic string, clock clockwork.Clock) *Coordinator {
+func NewCoordinator(producer, taskProducer sarama.AsyncProducer, db db.Client, createTopic, executeTopic string, clock clockwork.Clock) *Coordinator {
 	coord := &Coordinator{
 		producer:     producer,
+		taskProducer: taskProducer,
 		db:           db,
 		managers:     map[int]*Manager{},
 		createTopic:  createTopic,
@@ -35,6 +37,7 @@ func (c *Coordinator) Stop() {
 	}
 
 	c.producer.AsyncClose()
+	c.taskProducer.AsyncClose()
 }
 
 func (c *Coordinator) HandleRepartition(newPartitions []int32) {
@@ -55,7 +58,7 @@ func (c *Coordinator) HandleRepartition(newPartitions []int32) {
 
 func (c *Coordinator) GetManager(partition int) *Manager {
 	if _, ok := c.managers[partition]; !ok {
-		c.managers[partition] = newManager(c.producer.Input(), c.db, c.createTopic, c.executeTopic, partition, c.clock)
+		c.managers[partition] = newManager(c.producer.Input(), c.taskProducer.Input(), c.db, c.createTopic, c.executeTopic, partition, c.clock)
 	}
 	return c.managers[partition]
 }
@@ -11,6 +11,7 @@ import (
 	"go.uber.org/atomic"
 
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
 
 	"github.com/nivista/steady/timer"
 	"google.golang.org/protobuf/proto"
@@ -28,23 +29,30 @@ type Manager struct {
 	haveProgresses bool
 	started        *atomic.Bool
 
+	// creates, progresses and paused are kept for every timer, so paused timers can be resumed.
 	creates    map[string]*messaging.Create
 	progresses map[string]*messaging.Progress
+	paused     map[string]bool
+	triggers   map[string]map[string]*messaging.Trigger // triggers recieved before the manager started, by timer id and record key.
 	timers     map[string]timer.Timer
 	timersLock sync.Mutex
 
-	producer chan<- *sarama.ProducerMessage
-	clock    clockwork.Clock
+	producer     chan<- *sarama.ProducerMessage
+	taskProducer timer.Producer
+	clock        clockwork.Clock
 }
 
 // unexported because the lifecycle of managers is managed by coordinator.
-func newManager(producer chan<- *sarama.ProducerMessage, db db.Client, createTopic, executeTopic string, partition int, clock clockwork.Clock) *Manager {
+func newManager(producer, taskProducer chan<- *sarama.ProducerMessage, db db.Client, createTopic, executeTopic string, partition int, clock clockwork.Clock) *Manager {
 	manager := Manager{
 		timers:       make(map[string]timer.Timer),
 		progresses:   make(map[string]*messaging.Progress),
 		creates:      make(map[string]*messaging.Create),
+		paused:       make(map[string]bool),
+		triggers:     make(map[string]map[string]*messaging.Trigger),
 		db:           db,
 		producer:     producer,
+		taskProducer: syncProducer{input: taskProducer, createTopic: createTopic, executeTopic: executeTopic},
 		clock:        clock,
 		partition:    partition,
 		started:      atomic.NewBool(false),
@@ -53,9 +61,12 @@ func newManager(producer chan<- *sarama.ProducerMessage, db db.Client, createTop
 	}
 	// query from db
 	go func() {
-		var err error
+		var (
+			progresses map[string]*messaging.Progress
+			err        error
+		)
 		for i := 0; i < 10; i++ {
-			manager.progresses, err = db.GetProgresses(context.TODO(), partition)
+			progresses, err = db.GetProgresses(context.TODO(), partition)
 			if err != nil {
 				fmt.Printf("ERROR: getting progresses from db.")
 			} else {
@@ -66,7 +77,12 @@ func newManager(producer chan<- *sarama.ProducerMessage, db db.Client, createTop
 			// escalate to a panic if we can't reach database
 			panic(fmt.Errorf("unable to get progress from db: %w", err))
 		}
+
+		manager.timersLock.Lock()
+		manager.progresses = progresses
 		manager.haveProgresses = true
+		manager.timersLock.Unlock()
+
 		manager.attemptStart()
 	}()
 
@@ -81,8 +97,19 @@ func (m *Manager) attemptStart() {
 		s := m.started.CAS(false, true)
 		fmt.Println(s)
 		if s {
+			// timers paused before their create was recieved, that weren't created.
+			for id := range m.paused {
+				if _, ok := m.creates[id]; !ok {
+					delete(m.paused, id)
+				}
+			}
+
 			for id, create := range m.creates {
-				t, err := timer.NewWithProgress(create, m.progresses[id], m.executeTimerFunc(id), m.finishTimerFunc(id), m.clock)
+				if m.paused[id] {
+					continue
+				}
+
+				t, err := timer.NewWithProgress(create, timer.CurrentProgress(create, m.progresses[id]), m.executeTimerFunc(id), m.finishTimerFunc(id), m.clock, m.taskProducer)
 				if err == nil {
 					m.timers[id] = t
 					t.Start()
@@ -90,47 +117,174 @@ func (m *Manager) attemptStart() {
 					fmt.Printf("error timer.NewWithProgress with id %v: %v\n", id, err)
 				}
 			}
+
+			for id, triggers := range m.triggers {
+				for key, trigger := range triggers {
+					go m.runTrigger(id, []byte(key), m.creates[id], trigger)
+				}
+			}
+			m.triggers = nil
 		}
 	}
 }
 
-// CreateTimer adds a timer to the manager, and starts it if the manager is active.
+// CreateTimer adds a timer to the manager, and starts it if the manager is active and the timer isn't paused.
+// If the timer already exists it's an update, the old timer is stopped and the new one continues from its progress.
 func (m *Manager) CreateTimer(pk string, create *messaging.Create) {
-	if m.started.Load() {
-		t, err := timer.New(create, m.executeTimerFunc(pk), m.finishTimerFunc(pk), m.clock)
-		if err == nil {
-			fmt.Println("error constructing timer with id %v: %v", pk, err.Error())
-		} else {
-			m.timersLock.Lock()
-			m.timers[pk] = t
-			t.Start()
-			m.timersLock.Unlock()
-		}
-	} else {
+	// checked with the lock held, so the manager can't start between the check and recording the create.
+	m.timersLock.Lock()
+	if !m.started.Load() {
+		defer m.timersLock.Unlock()
+
 		err := timer.IsValid(create)
 		if err == nil {
 			m.creates[pk] = create
 		} else {
-			fmt.Println("recieved invalid create id %v: %v", create, err.Error())
+			fmt.Printf("recieved invalid create id %v: %v\n", pk, err.Error())
 		}
+		return
+	}
+
+	old, ok := m.timers[pk]
+	delete(m.timers, pk)
+	m.timersLock.Unlock()
+
+	// stopped synchronously without the lock, like in PauseTimer.
+	if ok {
+		old.Stop()
+	}
+
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+
+	prog := timer.CurrentProgress(create, m.progresses[pk])
+	t, err := timer.NewWithProgress(create, prog, m.executeTimerFunc(pk), m.finishTimerFunc(pk), m.clock, m.taskProducer)
+	if err != nil {
+		fmt.Printf("error constructing timer with id %v: %v\n", pk, err.Error())
+		return
+	}
+
+	m.creates[pk] = create
+	m.progresses[pk] = prog
+	if !m.paused[pk] {
+		m.timers[pk] = t
+		t.Start()
 	}
 }
 
 // RemoveTimer stops a timer if it is running and removes it from the manager.
+// pk includes the domain, so a delete from another domain has another pk and never removes the timer.
 func (m *Manager) RemoveTimer(pk string) {
-	if m.started.Load() {
-		m.timersLock.Lock()
-		go m.timers[pk].Stop()
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+
+	if t, ok := m.timers[pk]; ok {
+		go t.Stop()
 		delete(m.timers, pk)
+	}
+	delete(m.creates, pk)
+	delete(m.progresses, pk)
+	delete(m.paused, pk)
+	delete(m.triggers, pk)
+}
+
+// PauseTimer stops a timer if it is running, it's kept with its progress so it can be resumed.
+// Before the manager starts, a timer's state can be recieved before its create, which compaction may have moved
+// after it, so unknown timers are paused until the manager starts. After it starts, unknown timers are ignored.
+func (m *Manager) PauseTimer(pk string) {
+	m.timersLock.Lock()
+	if _, ok := m.creates[pk]; !ok && m.started.Load() {
 		m.timersLock.Unlock()
+		return
+	}
+	m.paused[pk] = true
+	t, ok := m.timers[pk]
+	delete(m.timers, pk)
+	m.timersLock.Unlock()
+
+	// stopped synchronously, so the timer can't overlap with a resumed timer.
+	// the lock isn't held, because the timer may be waiting on it to record an execution.
+	if ok {
+		t.Stop()
+	}
+}
+
+// ResumeTimer restarts a paused timer from its progress, if the manager is active.
+func (m *Manager) ResumeTimer(pk string) {
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+
+	if !m.paused[pk] {
+		return
+	}
+	delete(m.paused, pk)
+
+	create, ok := m.creates[pk]
+	if !m.started.Load() || !ok {
+		return
+	}
+
+	t, err := timer.NewWithProgress(create, timer.CurrentProgress(create, m.progresses[pk]), m.executeTimerFunc(pk), m.finishTimerFunc(pk), m.clock, m.taskProducer)
+	if err != nil {
+		fmt.Printf("error timer.NewWithProgress with id %v: %v\n", pk, err)
+		return
+	}
+	m.timers[pk] = t
+	t.Start()
+}
+
+// TriggerTimer executes a timers task once outside of its schedule, when the manager is active.
+// key is the key of the trigger's record, which is tombstoned once it's executed. Paused timers can be triggered.
+func (m *Manager) TriggerTimer(pk string, key []byte, trigger *messaging.Trigger) {
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+
+	if !m.started.Load() {
+		if m.triggers[pk] == nil {
+			m.triggers[pk] = make(map[string]*messaging.Trigger)
+		}
+		m.triggers[pk][string(key)] = trigger
+		return
+	}
+	go m.runTrigger(pk, key, m.creates[pk], trigger)
+}
+
+// RemoveTrigger forgets the trigger with record key recieved before the manager started, it was already executed.
+// Other pending triggers of the timer are kept.
+func (m *Manager) RemoveTrigger(pk string, key []byte) {
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+
+	delete(m.triggers[pk], string(key))
+	if len(m.triggers[pk]) == 0 {
+		delete(m.triggers, pk)
+	}
+}
+
+// runTrigger executes a trigger, records its execution and tombstones its record so it isn't executed again.
+func (m *Manager) runTrigger(pk string, key []byte, create *messaging.Create, trigger *messaging.Trigger) {
+	if create == nil {
+		fmt.Printf("trigger for unknown timer with id %v\n", pk)
+	} else if execMsg, err := timer.Trigger(create, trigger, m.clock, m.taskProducer); err != nil {
+		fmt.Printf("error timer.Trigger with id %v: %v\n", pk, err)
 	} else {
-		delete(m.creates, pk)
+		m.recordExecution(pk, execMsg)
+	}
+
+	m.producer <- &sarama.ProducerMessage{
+		Topic:     m.createTopic,
+		Key:       sarama.ByteEncoder(key),
+		Value:     nil,
+		Partition: int32(m.partition),
 	}
 }
 
 // RecievedDummy indicates that we've seen the dummy message, so creates recieved can be processed.
 func (m *Manager) RecievedDummy() {
+	m.timersLock.Lock()
 	m.haveCreates = true
+	m.timersLock.Unlock()
+
 	m.attemptStart()
 }
 
@@ -142,34 +296,63 @@ func (m *Manager) stop() {
 
 func (m *Manager) executeTimerFunc(pk string) func(execMsg *messaging.Execute) {
 	return func(execMsg *messaging.Execute) {
-		bytes, err := proto.Marshal(execMsg)
-		if err != nil {
-			fmt.Printf("progress update fn timerData w/ id %v, err proto.Marshal: %v\n", pk, err.Error())
-			return
-		}
+		m.timersLock.Lock()
+		m.progresses[pk] = execMsg.Progress
+		m.timersLock.Unlock()
 
-		m.producer <- &sarama.ProducerMessage{
-			Topic:     m.executeTopic,
-			Key:       sarama.StringEncoder(pk),
-			Value:     sarama.ByteEncoder(bytes),
-			Partition: int32(m.partition),
-		}
+		m.recordExecution(pk, execMsg)
+	}
+
+}
+
+func (m *Manager) recordExecution(pk string, execMsg *messaging.Execute) {
+	bytes, err := proto.Marshal(execMsg)
+	if err != nil {
+		fmt.Printf("progress update fn timerData w/ id %v, err proto.Marshal: %v\n", pk, err.Error())
+		return
 	}
 
+	m.producer <- &sarama.ProducerMessage{
+		Topic:     m.executeTopic,
+		Key:       sarama.StringEncoder(pk),
+		Value:     sarama.ByteEncoder(bytes),
+		Partition: int32(m.partition),
+	}
 }
 
 func (m *Manager) finishTimerFunc(pk string) func() {
 	return func() {
+		m.tombstone(pk, messaging.KeyKind_KEY_KIND_TRIGGER)
+		m.tombstone(pk, messaging.KeyKind_KEY_KIND_STATE)
+		m.tombstone(pk, messaging.KeyKind_KEY_KIND_CREATE)
+
 		m.producer <- &sarama.ProducerMessage{
-			Topic:     m.createTopic,
+			Topic:     m.executeTopic,
 			Key:       sarama.StringEncoder(pk),
 			Value:     nil,
 			Partition: int32(m.partition),
 		}
+	}
+}
+
+// tombstone deletes the record of the given kind of the timer with create key pk from the create topic, in every key version.
+func (m *Manager) tombstone(pk string, kind messaging.KeyKind) {
+	key, err := keys.Decode([]byte(pk))
+	if err != nil {
+		fmt.Printf("tombstone w/ id %v, err keys.Decode: %v\n", pk, err.Error())
+		return
+	}
+
+	for _, version := range keys.Versions {
+		kindKey, err := keys.WithKind(key, kind, version)
+		if err != nil {
+			fmt.Printf("tombstone w/ id %v, err keys.WithKind: %v\n", pk, err.Error())
+			continue
+		}
 
 		m.producer <- &sarama.ProducerMessage{
-			Topic:     m.executeTopic,
-			Key:       sarama.StringEncoder(pk),
+			Topic:     m.createTopic,
+			Key:       sarama.ByteEncoder(kindKey),
 			Value:     nil,
 			Partition: int32(m.partition),
 		}
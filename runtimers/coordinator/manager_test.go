@@ -0,0 +1,330 @@
+package coordinator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jonboulle/clockwork"
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testUUID = "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51"
+
+// fakeDB returns the same progresses for every partition.
+type fakeDB map[string]*messaging.Progress
+
+func (f fakeDB) GetProgresses(ctx context.Context, partition int) (map[string]*messaging.Progress, error) {
+	progresses := make(map[string]*messaging.Progress, len(f))
+	for id, prog := range f {
+		progresses[id] = prog
+	}
+	return progresses, nil
+}
+
+// newTestManager returns a manager that recieved the creates, and waits for it to start.
+func newTestManager(t *testing.T, db fakeDB, creates map[string]*messaging.Create) (*Manager, chan *sarama.ProducerMessage, clockwork.FakeClock) {
+	producer := make(chan *sarama.ProducerMessage, 100)
+	clock := clockwork.NewFakeClockAt(time.Unix(0, 0))
+	m := newManager(producer, producer, db, "create", "execute", 0, clock)
+
+	for pk, create := range creates {
+		m.CreateTimer(pk, create)
+	}
+	startTestManager(t, m)
+	return m, producer, clock
+}
+
+// startTestManager starts a manager that recieved its creates, and waits for it to start.
+func startTestManager(t *testing.T, m *Manager) {
+	m.RecievedDummy()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !m.started.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("manager didn't start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// getTestID returns the id of the test timer's record of the given kind written in the given key version.
+func getTestID(t *testing.T, kind messaging.KeyKind, version byte) string {
+	b, err := keys.EncodeVersion(&messaging.Key{Domain: "acme", TimerUUID: testUUID, Kind: kind}, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := keys.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := keys.ID(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// getTestCreate returns a timer that fires every hour from the epoch.
+func getTestCreate() *messaging.Create {
+	return &messaging.Create{
+		Task: &common.Task{
+			Task: &common.Task_Http{Http: &common.HTTP{Url: "http://localhost:1"}},
+		},
+		Schedule: &common.Schedule{
+			Spec:      &common.Schedule_Cron{Cron: "@every 1h"},
+			StartTime: timestamppb.New(time.Unix(0, 0)),
+		},
+		Meta: &common.Meta{
+			Domain:    "acme",
+			TimerUuid: testUUID,
+		},
+	}
+}
+
+func TestManagerProgressKeys(t *testing.T) {
+	prog := &messaging.Progress{
+		CompletedExecutions: 4,
+		LastExecution:       timestamppb.New(time.Unix(0, 0)),
+	}
+
+	// the db keys progress by the current version of the create key.
+	m, _, _ := newTestManager(t, fakeDB{getTestID(t, messaging.KeyKind_KEY_KIND_CREATE, keys.Version): prog}, nil)
+	defer m.stop()
+
+	// records in either version are the same timer, which continues from its progress.
+	legacyID := getTestID(t, messaging.KeyKind_KEY_KIND_CREATE, keys.LegacyVersion)
+	currentID := getTestID(t, messaging.KeyKind_KEY_KIND_STATE, keys.Version)
+	if legacyID != currentID {
+		t.Fatalf("expected legacy and current records to have the same id")
+	}
+
+	m.CreateTimer(legacyID, getTestCreate())
+
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+	if !proto.Equal(m.progresses[currentID], prog) {
+		t.Errorf("expected progress %v, got %v", prog, m.progresses[currentID])
+	}
+
+	if _, ok := m.timers[currentID]; !ok {
+		t.Errorf("expected timer to be running")
+	}
+}
+
+func TestManagerPendingTriggers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	create := getTestCreate()
+	create.Task = &common.Task{Task: &common.Task_Http{Http: &common.HTTP{Url: server.URL}}}
+
+	producer := make(chan *sarama.ProducerMessage, 100)
+	m := newManager(producer, producer, fakeDB{}, "create", "execute", 0, clockwork.NewFakeClockAt(time.Unix(0, 0)))
+	defer m.stop()
+
+	pk := getTestID(t, messaging.KeyKind_KEY_KIND_CREATE, keys.Version)
+	m.CreateTimer(pk, create)
+
+	// triggers pending at the same time have their own keys, executing one doesn't forget the others.
+	var triggerKeys [][]byte
+	for _, triggerID := range []string{"first", "second", "third"} {
+		key, err := keys.Encode(&messaging.Key{Domain: "acme", TimerUUID: testUUID, Kind: messaging.KeyKind_KEY_KIND_TRIGGER, TriggerId: triggerID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		triggerKeys = append(triggerKeys, key)
+		m.TriggerTimer(pk, key, &messaging.Trigger{TriggerId: triggerID})
+	}
+	m.RemoveTrigger(pk, triggerKeys[0])
+
+	startTestManager(t, m)
+
+	executed := map[string]bool{}
+	tombstoned := map[string]bool{}
+	for len(tombstoned) < 2 {
+		select {
+		case msg := <-producer:
+			if msg.Topic == "create" && msg.Value == nil {
+				key, err := msg.Key.Encode()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tombstoned[string(key)] = true
+				continue
+			}
+
+			value, err := msg.Value.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var execute messaging.Execute
+			if err = proto.Unmarshal(value, &execute); err != nil {
+				t.Fatal(err)
+			}
+			executed[execute.TriggerId] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected pending triggers to be executed, got %v and tombstones %v", executed, tombstoned)
+		}
+	}
+
+	if executed["first"] || !executed["second"] || !executed["third"] {
+		t.Errorf("expected the second and third triggers to be executed, got %v", executed)
+	}
+
+	// only the records of executed triggers are tombstoned.
+	for i, key := range triggerKeys {
+		if tombstoned[string(key)] != (i > 0) {
+			t.Errorf("case: trigger %v. unexpected tombstone %v", i, tombstoned[string(key)])
+		}
+	}
+}
+
+func TestManagerRemoveTimerOfOtherDomain(t *testing.T) {
+	pk := getTestID(t, messaging.KeyKind_KEY_KIND_CREATE, keys.Version)
+	m, _, _ := newTestManager(t, fakeDB{}, map[string]*messaging.Create{pk: getTestCreate()})
+	defer m.stop()
+
+	// a delete of the same uuid from another domain has another id.
+	otherPK, err := keys.ID(&messaging.Key{Domain: "other", TimerUUID: testUUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RemoveTimer(otherPK)
+
+	m.timersLock.Lock()
+	_, ok := m.timers[pk]
+	m.timersLock.Unlock()
+	if !ok {
+		t.Fatalf("expected timer to be running after a delete from another domain")
+	}
+
+	m.RemoveTimer(pk)
+
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+	if _, ok := m.timers[pk]; ok {
+		t.Errorf("expected timer to be removed")
+	}
+	if _, ok := m.creates[pk]; ok {
+		t.Errorf("expected create to be removed")
+	}
+}
+
+// isRunning returns whether the manager is running the timer, and whether it's paused.
+func isRunning(m *Manager, pk string) (running, paused bool) {
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+
+	_, running = m.timers[pk]
+	return running, m.paused[pk]
+}
+
+func TestManagerPauseResume(t *testing.T) {
+	pk := getTestID(t, messaging.KeyKind_KEY_KIND_CREATE, keys.Version)
+	unknownPK, err := keys.ID(&messaging.Key{Domain: "acme", TimerUUID: "0f3c2d1e-5b4a-4c3d-8e2f-1a0b9c8d7e6f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		// before is called before the manager starts, after once it started.
+		before, after func(m *Manager)
+		running       bool
+		paused        bool
+	}{
+		{
+			name:    "running",
+			before:  func(m *Manager) { m.CreateTimer(pk, getTestCreate()) },
+			after:   func(m *Manager) {},
+			running: true,
+		},
+		{
+			name:   "paused",
+			before: func(m *Manager) { m.CreateTimer(pk, getTestCreate()) },
+			after:  func(m *Manager) { m.PauseTimer(pk) },
+			paused: true,
+		},
+		{
+			name:   "resumed",
+			before: func(m *Manager) { m.CreateTimer(pk, getTestCreate()) },
+			after: func(m *Manager) {
+				m.PauseTimer(pk)
+				m.ResumeTimer(pk)
+			},
+			running: true,
+		},
+		{
+			// compaction keeps the latest create of an updated timer, after its state.
+			name: "paused before its create",
+			before: func(m *Manager) {
+				m.PauseTimer(pk)
+				m.CreateTimer(pk, getTestCreate())
+			},
+			after:  func(m *Manager) {},
+			paused: true,
+		},
+		{
+			name: "resumed after it was paused before its create",
+			before: func(m *Manager) {
+				m.PauseTimer(pk)
+				m.CreateTimer(pk, getTestCreate())
+			},
+			after:   func(m *Manager) { m.ResumeTimer(pk) },
+			running: true,
+		},
+		{
+			name: "updated while paused",
+			before: func(m *Manager) {
+				m.CreateTimer(pk, getTestCreate())
+				m.PauseTimer(pk)
+			},
+			after:  func(m *Manager) { m.CreateTimer(pk, getTestCreate()) },
+			paused: true,
+		},
+		{
+			name:   "deleted while paused",
+			before: func(m *Manager) { m.CreateTimer(pk, getTestCreate()) },
+			after: func(m *Manager) {
+				m.PauseTimer(pk)
+				m.RemoveTimer(pk)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		m := newManager(make(chan *sarama.ProducerMessage, 100), nil, fakeDB{}, "create", "execute", 0, clockwork.NewFakeClockAt(time.Unix(0, 0)))
+		test.before(m)
+		startTestManager(t, m)
+		test.after(m)
+
+		if running, paused := isRunning(m, pk); running != test.running || paused != test.paused {
+			t.Errorf("case: %v. expected running %v and paused %v, got %v and %v", test.name, test.running, test.paused, running, paused)
+		}
+		m.stop()
+	}
+
+	// unknown timers paused before the manager starts are forgotten once it starts, and after it starts they're ignored.
+	m := newManager(make(chan *sarama.ProducerMessage, 100), nil, fakeDB{}, "create", "execute", 0, clockwork.NewFakeClockAt(time.Unix(0, 0)))
+	defer m.stop()
+	m.PauseTimer(unknownPK)
+	startTestManager(t, m)
+	m.PauseTimer(pk)
+
+	m.timersLock.Lock()
+	defer m.timersLock.Unlock()
+	if len(m.paused) != 0 {
+		t.Errorf("expected unknown timers not to be paused, got %v", m.paused)
+	}
+}
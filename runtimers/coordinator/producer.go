@@ -0,0 +1,44 @@
+package coordinator
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/nivista/steady/timer"
+)
+
+// syncProducer sends the messages of kafka publish tasks through the task producer, and waits for them to be acknowledged.
+// It relies on the producers successes and errors being passed to HandleProducerResult.
+type syncProducer struct {
+	input                     chan<- *sarama.ProducerMessage
+	createTopic, executeTopic string
+}
+
+// SendMessage implements timer.Producer.
+func (p syncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	if msg.Topic == p.createTopic || msg.Topic == p.executeTopic {
+		// timer.IsValid only allows the domain's topics, this keeps steady's topics safe regardless.
+		return -1, -1, fmt.Errorf("%w %v, it's one of steady's own topics", timer.ErrForbiddenTopic, msg.Topic)
+	}
+
+	done := make(chan error, 1)
+	msg.Metadata = done
+	p.input <- msg
+
+	if err := <-done; err != nil {
+		return -1, -1, err
+	}
+	return msg.Partition, msg.Offset, nil
+}
+
+// HandleProducerResult notifies the sender of a message sent by syncProducer that it was acknowledged or failed.
+// It returns false if the message wasn't sent by a syncProducer.
+func HandleProducerResult(msg *sarama.ProducerMessage, err error) bool {
+	done, ok := msg.Metadata.(chan error)
+	if !ok {
+		return false
+	}
+
+	done <- err
+	return true
+}
@@ -5,16 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"strings"
+	"io/ioutil"
+	"net/http"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/esapi"
 	elasticsearch "github.com/elastic/go-elasticsearch/v8"
+	"github.com/nivista/steady/elastic"
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type (
 	Client interface {
+		// GetProgresses returns the progress of the timers of a partition, by timer id.
 		GetProgresses(ctx context.Context, partition int) (map[string]*messaging.Progress, error)
 	}
 
@@ -22,6 +27,18 @@ type (
 		elastic       *elasticsearch.Client
 		progressIndex string
 	}
+
+	// scrollSearch is a search response with a scroll id.
+	scrollSearch struct {
+		ScrollID string `json:"_scroll_id"`
+		elastic.Search
+	}
+)
+
+// progresses are read in pages of scrollSize, keeping the scroll open for scrollKeepAlive between pages.
+const (
+	scrollSize      = 1000
+	scrollKeepAlive = time.Minute
 )
 
 func NewClient(elastic *elasticsearch.Client, progressIndex string) Client {
@@ -31,40 +48,123 @@ func NewClient(elastic *elasticsearch.Client, progressIndex string) Client {
 	}
 }
 
-type elasticFormat struct {
-	Hits []struct {
-		ID     *string             `json:"_id"`
-		Source *messaging.Progress `json:"_source"`
-	} `json:"hits"`
-}
-
 func (c *client) GetProgresses(ctx context.Context, partition int) (map[string]*messaging.Progress, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{"term": map[string]interface{}{"partition": partition}},
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	size := scrollSize
 	req := esapi.SearchRequest{
-		Index: []string{c.progressIndex},
-		Body:  strings.NewReader(fmt.Sprint(`{"query": { "match" : { "partition": `, partition, `}}}`)),
+		Index:  []string{c.progressIndex},
+		Body:   bytes.NewReader(body),
+		Size:   &size,
+		Sort:   []string{"_doc"},
+		Scroll: scrollKeepAlive,
 	}
-
 	res, err := req.Do(ctx, c.elastic)
 	if err != nil {
 		return nil, err
 	}
 
+	progresses := make(map[string]*messaging.Progress)
+	for {
+		search, err := readScrollSearch(res)
+		if err != nil {
+			return nil, err
+		}
+
+		// the index doesn't exist until the first execution is recorded.
+		if search == nil {
+			return progresses, nil
+		}
+
+		if len(search.Hits.Hits) == 0 {
+			c.clearScroll(ctx, search.ScrollID)
+			return progresses, nil
+		}
+
+		if err = addProgresses(progresses, search.Hits.Hits); err != nil {
+			c.clearScroll(ctx, search.ScrollID)
+			return nil, err
+		}
+
+		scrollReq := esapi.ScrollRequest{
+			ScrollID: search.ScrollID,
+			Scroll:   scrollKeepAlive,
+		}
+		if res, err = scrollReq.Do(ctx, c.elastic); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readScrollSearch reads and closes a search or scroll response, it returns nil if the index doesn't exist.
+func readScrollSearch(res *esapi.Response) (*scrollSearch, error) {
 	defer res.Body.Close()
-	var b bytes.Buffer
-	io.Copy(&b, res.Body)
 
-	var eF elasticFormat
-	if err = json.Unmarshal(b.Bytes(), &eF); err != nil {
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if res.IsError() {
+		return nil, fmt.Errorf("searching progresses: %v", res.String())
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
 		return nil, err
 	}
 
-	out := make(map[string]*messaging.Progress)
+	var search scrollSearch
+	if err = json.Unmarshal(data, &search); err != nil {
+		return nil, err
+	}
+	return &search, nil
+}
 
-	for _, hit := range eF.Hits {
-		out[*hit.ID] = hit.Source
+func (c *client) clearScroll(ctx context.Context, scrollID string) {
+	req := esapi.ClearScrollRequest{ScrollID: []string{scrollID}}
+	res, err := req.Do(ctx, c.elastic)
+	if err != nil {
+		fmt.Printf("clearing progress scroll: %v\n", err)
+		return
 	}
+	res.Body.Close()
+}
+
+// addProgresses adds the progresses of hits from the progress index, by the id of their timer.
+// Their _id is the timer uuid, and they record the timer's domain.
+func addProgresses(progresses map[string]*messaging.Progress, hits []elastic.Hit) error {
+	for _, hit := range hits {
+		var prog elastic.Progress
+		if err := json.Unmarshal(hit.Source, &prog); err != nil {
+			return fmt.Errorf("progress %v: %w", hit.ID, err)
+		}
 
-	return out, nil
+		id, err := keys.ID(&messaging.Key{
+			Domain:    prog.Domain,
+			TimerUUID: hit.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("progress %v: %w", hit.ID, err)
+		}
 
+		progresses[id] = &messaging.Progress{
+			CompletedExecutions: int32(prog.CompletedExecutions),
+			LastExecution:       getTimestamp(prog.LastExecution),
+			LastScheduled:       getTimestamp(prog.LastScheduled),
+		}
+	}
+	return nil
+}
+
+// getTimestamp returns nil for unset times, which are null, or the unix epoch in progress indexed before.
+func getTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || !t.After(time.Unix(0, 0)) {
+		return nil
+	}
+	return timestamppb.New(*t)
 }
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nivista/steady/elastic"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/internal/keys"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAddProgresses(t *testing.T) {
+	lastExecution := time.Date(2020, 6, 1, 12, 0, 0, 5, time.UTC)
+	lastScheduled := time.Date(2020, 6, 1, 11, 59, 59, 0, time.UTC)
+	source, err := json.Marshal(elastic.Progress{
+		Domain:              "acme",
+		Partition:           3,
+		LastExecution:       &lastExecution,
+		LastScheduled:       &lastScheduled,
+		CompletedExecutions: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// progress indexed before unset times were null has the unix epoch.
+	epochSource := []byte(`{"domain":"acme","partition":3,"last_execution":"2020-06-01T12:00:00Z","last_scheduled":"1970-01-01T00:00:00Z","result":1}`)
+	nullSource := []byte(`{"domain":"acme","partition":3,"last_execution":"2020-06-01T12:00:00Z","last_scheduled":null,"result":1}`)
+
+	progresses := map[string]*messaging.Progress{}
+	err = addProgresses(progresses, []elastic.Hit{
+		{ID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Source: source},
+		{ID: "epoch", Source: epochSource},
+		{ID: "null", Source: nullSource},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, uuid := range []string{"epoch", "null"} {
+		id, err := keys.ID(&messaging.Key{Domain: "acme", TimerUUID: uuid})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if prog := progresses[id]; prog == nil || prog.LastExecution == nil || prog.LastScheduled != nil {
+			t.Errorf("case: %v. unexpected progress %v", uuid, prog)
+		}
+	}
+
+	key := &messaging.Key{Domain: "acme", TimerUUID: "8c5e1f0e-6a7b-4d47-9a3e-0f7d3c0c8a51", Kind: messaging.KeyKind_KEY_KIND_STATE}
+	legacy, err := proto.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := keys.Encode(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the manager looks progress up by the id of records of any version.
+	var tests = []struct {
+		name string
+		b    []byte
+	}{
+		{"legacy", legacy},
+		{"current", current},
+	}
+
+	for _, test := range tests {
+		decoded, err := keys.Decode(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := keys.ID(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		prog, ok := progresses[id]
+		if !ok {
+			t.Errorf("case: %v. progress not found", test.name)
+			continue
+		}
+
+		if prog.CompletedExecutions != 4 || !prog.LastExecution.AsTime().Equal(lastExecution) || !prog.LastScheduled.AsTime().Equal(lastScheduled) {
+			t.Errorf("case: %v. unexpected progress %v", test.name, prog)
+		}
+	}
+}
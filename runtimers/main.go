@@ -33,6 +33,9 @@ const (
 	kafkaGroupID         = "KAFKA_GROUP_ID"
 	elasticURL           = "ELASTIC_URL"
 	elasticProgressIndex = "ELASTIC_PROGRESS_INDEX"
+
+	// the key the signing secrets of http tasks are derived from, the same as the webservice's. See docs/signing.md.
+	signingKey = "STEADY_SIGNING_KEY"
 )
 
 func main() {
@@ -45,6 +48,11 @@ func main() {
 	viper.SetDefault(kafkaBrokers, "localhost:9092")
 	viper.SetDefault(kafkaVersion, "2.2.1")
 	viper.SetDefault(kafkaGroupID, "executers")
+	viper.SetDefault(signingKey, "")
+	// the key is a secret, so it can be set in the environment rather than the config file.
+	if err := viper.BindEnv(signingKey); err != nil {
+		panic(err)
+	}
 
 	if *configURL != "" {
 		viper.AddConfigPath(*configURL)
@@ -57,6 +65,10 @@ func main() {
 		}
 	}
 
+	if viper.GetString(signingKey) == "" {
+		log.Printf("%v isn't set, http tasks won't be signed.\n", signingKey)
+	}
+
 	// set up elastic
 	elasticCfg := elasticsearch.Config{
 		Addresses: []string{viper.GetString(elasticURL)},
@@ -94,8 +106,32 @@ func main() {
 		}
 	}()
 
+	// kafka publish tasks have their own producer, they wait for acks to record the offset of their messages.
+	taskConfig := sarama.NewConfig()
+	taskConfig.Version = version
+	taskConfig.Producer.RequiredAcks = sarama.WaitForLocal
+	taskConfig.Producer.Retry.Max = 10
+	taskConfig.Producer.Return.Successes = true
+
+	taskProducer, err := sarama.NewAsyncProducer(viper.GetStringSlice("KAFKA_BROKERS"), taskConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		for err := range taskProducer.Errors() {
+			coordinator.HandleProducerResult(err.Msg, err.Err)
+		}
+	}()
+
+	go func() {
+		for msg := range taskProducer.Successes() {
+			coordinator.HandleProducerResult(msg, nil)
+		}
+	}()
+
 	// set up coordinator
-	coord := coordinator.NewCoordinator(producer, db, viper.GetString(createTopic), viper.GetString(executeTopic), clockwork.NewRealClock())
+	coord := coordinator.NewCoordinator(producer, taskProducer, db, viper.GetString(createTopic), viper.GetString(executeTopic), clockwork.NewRealClock())
 
 	// set up consumer
 	consumer := consumer.NewConsumer(producer, coord, viper.GetInt32(partitions), viper.GetString(nodeID), viper.GetString(createTopic))
@@ -0,0 +1,128 @@
+// Package signature signs and verifies the requests steady makes to http endpoints.
+//
+// Every request carries the Steady-Signature header, of the form "t=<unix timestamp>,v1=<hex hmac>".
+// The HMAC is a SHA256 HMAC, keyed by the domain's signing secret, of the timestamp, timer UUID and body joined by periods.
+// See docs/signing.md for configuring the signing key.
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers steady sets on http requests.
+const (
+	SignatureHeader   = "Steady-Signature"
+	TimerUUIDHeader   = "Steady-Timer-Uuid"
+	ExecutionIDHeader = "Steady-Execution-Id"
+)
+
+// DefaultTolerance is the maximum age of a signature accepted by VerifyRequest.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidHeader is returned when the signature header is missing or malformed.
+	ErrInvalidHeader = errors.New("invalid signature header")
+
+	// ErrNoValidSignature is returned when no signature in the header matches the request.
+	ErrNoValidSignature = errors.New("no valid signature")
+
+	// ErrTooOld is returned when the signature's timestamp is outside of the tolerance.
+	ErrTooOld = errors.New("signature timestamp outside of tolerance")
+)
+
+// DomainSecret derives a domain's signing secret from steady's signing key.
+// Every secret is derived from the one key, so one domain's secret can't be rotated without the others.
+func DomainSecret(signingKey, domain string) string {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(domain))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the value of the signature header for a request.
+func Sign(secret string, timestamp time.Time, timerUUID string, body []byte) string {
+	return fmt.Sprintf("t=%v,v1=%v", timestamp.Unix(), hex.EncodeToString(computeMAC(secret, timestamp.Unix(), timerUUID, body)))
+}
+
+// Verify checks the value of a signature header against the timer UUID and body of a request.
+// Signatures older than tolerance are rejected, unless tolerance is zero.
+func Verify(secret, header, timerUUID string, body []byte, tolerance time.Duration) error {
+	var timestamp int64
+	var signatures [][]byte
+
+	for _, pair := range strings.Split(header, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return ErrInvalidHeader
+		}
+
+		switch kv[0] {
+		case "t":
+			t, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return ErrInvalidHeader
+			}
+			timestamp = t
+
+		case "v1":
+			sig, err := hex.DecodeString(kv[1])
+			if err != nil {
+				continue
+			}
+			signatures = append(signatures, sig)
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidHeader
+	}
+
+	if tolerance != 0 && time.Since(time.Unix(timestamp, 0)) > tolerance {
+		return ErrTooOld
+	}
+
+	expected := computeMAC(secret, timestamp, timerUUID, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+
+	return ErrNoValidSignature
+}
+
+// VerifyRequest verifies an incoming request from steady with DefaultTolerance.
+// The request body is read and replaced, so it can be read again by the caller.
+func VerifyRequest(secret string, r *http.Request) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	return Verify(secret, r.Header.Get(SignatureHeader), r.Header.Get(TimerUUIDHeader), body, DefaultTolerance)
+}
+
+func computeMAC(secret string, timestamp int64, timerUUID string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write([]byte(timerUUID))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
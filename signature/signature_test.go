@@ -0,0 +1,66 @@
+package signature
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	secret    = "secret"
+	timerUUID = "8e0a8c2e-1f5a-4a55-9a7f-4e3f0b6c1d2e"
+)
+
+var verifyTests = []struct {
+	header    string
+	timerUUID string
+	body      string
+	tolerance time.Duration
+	err       error
+}{
+	{Sign(secret, time.Now(), timerUUID, []byte("body")), timerUUID, "body", time.Minute, nil},
+	{Sign(secret, time.Unix(1, 0), timerUUID, []byte("body")), timerUUID, "body", 0, nil}, // no tolerance
+	{Sign(secret, time.Now(), timerUUID, nil), timerUUID, "", time.Minute, nil},
+	{Sign(secret, time.Now(), timerUUID, []byte("body")), timerUUID, "tampered", time.Minute, ErrNoValidSignature},
+	{Sign(secret, time.Now(), timerUUID, []byte("body")), "other-uuid", "body", time.Minute, ErrNoValidSignature},
+	{Sign("other secret", time.Now(), timerUUID, []byte("body")), timerUUID, "body", time.Minute, ErrNoValidSignature},
+	{Sign(secret, time.Now().Add(-time.Hour), timerUUID, []byte("body")), timerUUID, "body", time.Minute, ErrTooOld},
+	{"", timerUUID, "body", time.Minute, ErrInvalidHeader},
+	{"t=abc,v1=00", timerUUID, "body", time.Minute, ErrInvalidHeader},
+	{"t=1", timerUUID, "body", 0, ErrInvalidHeader},
+}
+
+func TestVerify(t *testing.T) {
+	for idx, tt := range verifyTests {
+		if err := Verify(secret, tt.header, tt.timerUUID, []byte(tt.body), tt.tolerance); err != tt.err {
+			t.Errorf("case: %v. got error %v, expected %v", idx, err, tt.err)
+		}
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	r, _ := http.NewRequest("POST", "http://example.com", bytes.NewReader([]byte("body")))
+	r.Header.Set(SignatureHeader, Sign(secret, time.Now(), timerUUID, []byte("body")))
+	r.Header.Set(TimerUUIDHeader, timerUUID)
+
+	if err := VerifyRequest(secret, r); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if string(body) != "body" {
+		t.Errorf("body wasn't restored, got %q", body)
+	}
+}
+
+func TestDomainSecret(t *testing.T) {
+	if DomainSecret("key", "a") == DomainSecret("key", "b") {
+		t.Error("domains share a secret")
+	}
+
+	if DomainSecret("key", "a") == DomainSecret("other key", "a") {
+		t.Error("signing keys share a secret")
+	}
+}
@@ -0,0 +1,112 @@
+package timer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/nivista/steady/.gen/protos/common"
+)
+
+type (
+	// calendar is a validated common.Calendar, its dates are converted to ranges.
+	calendar struct {
+		name   string
+		ranges []timeRange
+	}
+
+	timeRange struct {
+		start, end time.Time
+	}
+
+	// skippedResult for JSON marshalling
+	skippedResult struct {
+		Skipped  bool
+		Calendar string
+	}
+)
+
+var validCalendarName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// IsValidCalendar validates a calendar.
+func IsValidCalendar(pb *common.Calendar) error {
+	_, err := newCalendar(pb)
+	return err
+}
+
+func newCalendar(pb *common.Calendar) (*calendar, error) {
+	if !validCalendarName.MatchString(pb.GetName()) {
+		return nil, errors.New("name must be 1 to 64 letters, digits, underscores or dashes")
+	}
+
+	loc, err := time.LoadLocation(pb.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	cal := calendar{name: pb.Name}
+	for _, date := range pb.Dates {
+		day, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %v", date)
+		}
+		cal.ranges = append(cal.ranges, timeRange{start: day, end: day.AddDate(0, 0, 1)})
+	}
+
+	for _, r := range pb.Ranges {
+		if r.Start.CheckValid() != nil || r.End.CheckValid() != nil {
+			return nil, errors.New("range requires a valid start and end")
+		}
+
+		if !r.End.AsTime().After(r.Start.AsTime()) {
+			return nil, errors.New("range end must be after its start")
+		}
+		cal.ranges = append(cal.ranges, timeRange{start: r.Start.AsTime(), end: r.End.AsTime()})
+	}
+
+	return &cal, nil
+}
+
+// newCalendars returns the calendars with the given names.
+func newCalendars(names []string, pbs []*common.Calendar) ([]*calendar, error) {
+	byName := make(map[string]*common.Calendar, len(pbs))
+	for _, pb := range pbs {
+		byName[pb.GetName()] = pb
+	}
+
+	calendars := make([]*calendar, 0, len(names))
+	for _, name := range names {
+		pb, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown calendar %v", name)
+		}
+
+		cal, err := newCalendar(pb)
+		if err != nil {
+			return nil, fmt.Errorf("calendar %v: %w", name, err)
+		}
+		calendars = append(calendars, cal)
+	}
+
+	return calendars, nil
+}
+
+func (c *calendar) excludes(t time.Time) bool {
+	for _, r := range c.ranges {
+		if !t.Before(r.start) && t.Before(r.end) {
+			return true
+		}
+	}
+	return false
+}
+
+func getSkippedJSON(calendar string) []byte {
+	json, err := json.Marshal(skippedResult{Skipped: true, Calendar: calendar})
+	if err != nil { // this should never happen
+		fmt.Printf("marshalling skipped result: %v\n", err.Error())
+		return getErrorJSON(errorKindSystem, "steady system error.")
+	}
+	return json
+}
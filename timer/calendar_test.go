@@ -0,0 +1,68 @@
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nivista/steady/.gen/protos/common"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestCalendarExcludes(t *testing.T) {
+	cal, err := newCalendar(&common.Calendar{
+		Name:     "holidays",
+		Timezone: "America/New_York",
+		Dates:    []string{"2020-12-25"},
+		Ranges: []*common.TimeRange{{
+			Start: timestamppb.New(time.Date(2020, 12, 1, 2, 0, 0, 0, time.UTC)),
+			End:   timestamppb.New(time.Date(2020, 12, 1, 4, 0, 0, 0, time.UTC)),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("newCalendar: %v", err)
+	}
+
+	var tests = []struct {
+		name     string
+		t        time.Time
+		excluded bool
+	}{
+		{"start of the date in its time zone", time.Date(2020, 12, 25, 5, 0, 0, 0, time.UTC), true},
+		{"end of the date in its time zone", time.Date(2020, 12, 26, 4, 59, 0, 0, time.UTC), true},
+		{"date in UTC, but not in its time zone", time.Date(2020, 12, 25, 1, 0, 0, 0, time.UTC), false},
+		{"day after the date", time.Date(2020, 12, 26, 5, 0, 0, 0, time.UTC), false},
+		{"start of the range", time.Date(2020, 12, 1, 2, 0, 0, 0, time.UTC), true},
+		{"end of the range", time.Date(2020, 12, 1, 4, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, test := range tests {
+		if excluded := cal.excludes(test.t); excluded != test.excluded {
+			t.Errorf("case: %v. got excluded %v, expected %v", test.name, excluded, test.excluded)
+		}
+	}
+}
+
+func TestInvalidCalendar(t *testing.T) {
+	start := timestamppb.New(time.Unix(60, 0))
+
+	var tests = []struct {
+		name string
+		pb   *common.Calendar
+	}{
+		{"no name", &common.Calendar{}},
+		{"invalid name", &common.Calendar{Name: "has spaces"}},
+		{"invalid timezone", &common.Calendar{Name: "cal", Timezone: "America/Nowhere"}},
+		{"invalid date", &common.Calendar{Name: "cal", Dates: []string{"12/25/2020"}}},
+		{"range without end", &common.Calendar{Name: "cal", Ranges: []*common.TimeRange{{Start: start}}}},
+		{"range ending before it starts", &common.Calendar{Name: "cal", Ranges: []*common.TimeRange{{
+			Start: start,
+			End:   timestamppb.New(time.Unix(0, 0)),
+		}}}},
+	}
+
+	for _, test := range tests {
+		if err := IsValidCalendar(test.pb); err == nil {
+			t.Errorf("case: %v. expected error", test.name)
+		}
+	}
+}
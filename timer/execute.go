@@ -1,23 +1,114 @@
 package timer
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/nivista/steady/.gen/protos/common"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
-type execute func() []byte
+type (
+	execute func(ctx context.Context, e execution) attempt
 
-func newExecute(t *common.Task) (execute, error) {
-	switch task := t.Task.(type) {
+	// execution describes the fire an attempt belongs to, it's the same for every attempt of a fire but attemptTime.
+	execution struct {
+		id        string
+		timerUUID string
+		domain    string
+		number    int32 // one based, zero for manual triggers.
+
+		scheduledTime time.Time // when the fire was scheduled for, without jitter.
+		fireTime      time.Time // when the fire actually happened.
+		attemptTime   time.Time // when the attempt started, by the timers clock.
+	}
+
+	// attempt is the outcome of a single try of a task.
+	attempt struct {
+		result       []byte // JSON result of the attempt.
+		statusCode   int    // status code of the response, zero if there wasn't one.
+		transportErr bool   // whether the attempt failed without a response.
+		success      bool   // whether the attempt met the tasks success criteria.
+	}
+)
+
+// kinds of errors recorded in execution results.
+const (
+	errorKindTimeout   = "timeout"
+	errorKindCanceled  = "canceled"
+	errorKindTransport = "transport"
+	errorKindSystem    = "system"
+)
+
+// errorResult for JSON marshalling
+type errorResult struct {
+	Error, ErrorKind string
+}
+
+func newExecute(t *common.Task, domain string, producer Producer) (execute, error) {
+	switch task := t.GetTask().(type) {
 	case *common.Task_Http:
 		return newHTTP(task.Http)
+	case *common.Task_Grpc:
+		return newGRPC(task.Grpc)
+	case *common.Task_KafkaPublish:
+		return newKafkaPublish(task.KafkaPublish, domain, producer)
 	default:
 		return nil, errors.New("unknown task")
 	}
 }
 
-func getErrorJSON(err string) []byte {
-	return []byte(fmt.Sprintf("{\"Error\":\"%v\"}", err))
+func getErrorJSON(kind, err string) []byte {
+	json, marshalErr := json.Marshal(errorResult{Error: err, ErrorKind: kind})
+	if marshalErr != nil { // this should never happen
+		fmt.Printf("marshalling error result: %v\n", marshalErr.Error())
+		return []byte(`{"Error":"steady system error.","ErrorKind":"system"}`)
+	}
+	return json
+}
+
+// getErrorAttempt returns the attempt for an error that occured while executing with ctx.
+func getErrorAttempt(ctx context.Context, err error) attempt {
+	kind := getErrorKind(ctx)
+	return attempt{
+		result:       getErrorJSON(kind, err.Error()),
+		transportErr: kind != errorKindCanceled,
+	}
+}
+
+// getErrorKind classifies an error that occured while executing with ctx.
+func getErrorKind(ctx context.Context) string {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return errorKindTimeout
+	case context.Canceled:
+		return errorKindCanceled
+	default:
+		return errorKindTransport
+	}
+}
+
+// getTimeout validates a tasks timeout, def is used if pb is nil.
+func getTimeout(pb *durationpb.Duration, def, max time.Duration) (time.Duration, error) {
+	if pb == nil {
+		return def, nil
+	}
+
+	if err := pb.CheckValid(); err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	timeout := pb.AsDuration()
+	if timeout <= 0 {
+		return 0, errors.New("timeout must be positive")
+	}
+
+	if timeout > max {
+		return 0, fmt.Errorf("timeout exceeds maximum of %v", max)
+	}
+
+	return timeout, nil
 }
@@ -0,0 +1,160 @@
+package timer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcDefaultTimeoutKey string = "STEADY_GRPC_DEFAULT_TIMEOUT"
+	grpcMaxTimeoutKey     string = "STEADY_GRPC_MAX_TIMEOUT"
+
+	grpcDefaultTimeout time.Duration
+	grpcMaxTimeout     time.Duration
+)
+
+func init() {
+	viper.SetDefault(grpcDefaultTimeoutKey, 10*time.Second)
+	viper.SetDefault(grpcMaxTimeoutKey, time.Minute)
+
+	grpcDefaultTimeout = viper.GetDuration(grpcDefaultTimeoutKey)
+	grpcMaxTimeout = viper.GetDuration(grpcMaxTimeoutKey)
+}
+
+type (
+	// grpcResponse for JSON marshalling
+	grpcResponse struct {
+		StatusCode        int
+		Status, Message   string
+		Error, ErrorKind  string
+		Response          []byte
+		Headers, Trailers map[string][]string
+		Success           bool
+	}
+
+	// rawCodec passes already encoded messages through, so calls don't need the message types.
+	rawCodec struct{}
+)
+
+func newGRPC(pb *common.GRPC) (execute, error) {
+	if pb.Target == "" {
+		return nil, errors.New("target required")
+	}
+
+	if !strings.HasPrefix(pb.Method, "/") || strings.Count(pb.Method, "/") != 2 || strings.HasSuffix(pb.Method, "/") {
+		return nil, errors.New("method must be of the form /package.Service/Method")
+	}
+
+	if int64(len(pb.Request)) > maxRequestBodySize {
+		return nil, errors.New("request too large")
+	}
+
+	md := metadata.MD{}
+	for key, value := range pb.Metadata {
+		key = strings.ToLower(key)
+		if strings.HasPrefix(key, "grpc-") {
+			return nil, fmt.Errorf("reserved metadata key %v", key)
+		}
+		md[key] = strings.Split(value, ",")
+	}
+
+	timeout, err := getTimeout(pb.Timeout, grpcDefaultTimeout, grpcMaxTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := grpc.WithInsecure()
+	if pb.Tls.GetEnabled() {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			ServerName:         pb.Tls.ServerName,
+			InsecureSkipVerify: pb.Tls.InsecureSkipVerify,
+		}))
+	}
+
+	return getGRPCExecute(pb.Target, pb.Method, pb.Request, md, creds, timeout), nil
+}
+
+func getGRPCExecute(target, method string, request []byte, md metadata.MD, creds grpc.DialOption, timeout time.Duration) execute {
+	return func(ctx context.Context, e execution) attempt {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		conn, err := grpc.DialContext(ctx, target, creds)
+		if err != nil {
+			return getErrorAttempt(ctx, err)
+		}
+		defer conn.Close()
+
+		var response []byte
+		var header, trailer metadata.MD
+		err = conn.Invoke(metadata.NewOutgoingContext(ctx, md), method, request, &response,
+			grpc.ForceCodec(rawCodec{}),
+			grpc.Header(&header),
+			grpc.Trailer(&trailer),
+			grpc.MaxCallRecvMsgSize(int(maxResponseBodySize)),
+		)
+
+		st := status.Convert(err)
+
+		var result grpcResponse
+		result.StatusCode = int(st.Code())
+		result.Status = st.Code().String()
+		result.Message = st.Message()
+		result.Response = response
+		result.Headers = header
+		result.Trailers = trailer
+		result.Success = st.Code() == codes.OK
+
+		if ctx.Err() != nil {
+			result.Error = ctx.Err().Error()
+			result.ErrorKind = getErrorKind(ctx)
+		}
+
+		json, err := json.Marshal(result)
+		if err != nil { // this should never happen
+			fmt.Printf("marshalling result: %v\n", err.Error())
+			return attempt{result: getErrorJSON(errorKindSystem, "steady system error.")}
+		}
+
+		return attempt{
+			result:       json,
+			statusCode:   result.StatusCode,
+			transportErr: st.Code() == codes.Unavailable || result.ErrorKind == errorKindTimeout,
+			success:      result.Success,
+		}
+	}
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("rawCodec can't marshal %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("rawCodec can't unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "raw"
+}
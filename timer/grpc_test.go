@@ -0,0 +1,128 @@
+package timer
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/nivista/steady/.gen/protos/common"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// record the metadata of incoming calls.
+	incoming := make(chan metadata.MD, 10)
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		incoming <- md
+		return handler(ctx, req)
+	}))
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	var testCases = []struct {
+		service string
+		code    codes.Code
+	}{
+		{"", codes.OK}, // the health server reports the overall server status as the empty service.
+		{"unknown", codes.NotFound},
+	}
+
+	for idx, tc := range testCases {
+		request, err := proto.Marshal(&grpc_health_v1.HealthCheckRequest{Service: tc.service})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exec, err := newGRPC(&common.GRPC{
+			Target:   lis.Addr().String(),
+			Method:   "/grpc.health.v1.Health/Check",
+			Request:  request,
+			Metadata: map[string]string{"X-Steady": "test"},
+		})
+		if err != nil {
+			t.Errorf("case: %v. newGRPC: %v", idx, err)
+			continue
+		}
+
+		a := exec(context.Background(), execution{})
+
+		var res grpcResponse
+		if err := json.Unmarshal(a.result, &res); err != nil {
+			t.Errorf("case: %v. unmarshalling result: %v", idx, err)
+			continue
+		}
+
+		if res.StatusCode != int(tc.code) || a.statusCode != int(tc.code) || a.success != (tc.code == codes.OK) {
+			t.Errorf("case: %v. got status %v %q, expected %v", idx, res.StatusCode, res.Message, tc.code)
+		}
+
+		md := <-incoming
+		if v := md.Get("x-steady"); len(v) != 1 || v[0] != "test" {
+			t.Errorf("case: %v. metadata not sent, got %v", idx, md)
+		}
+
+		if tc.code != codes.OK {
+			continue
+		}
+
+		var response grpc_health_v1.HealthCheckResponse
+		if err := proto.Unmarshal(res.Response, &response); err != nil {
+			t.Errorf("case: %v. unmarshalling response: %v", idx, err)
+			continue
+		}
+
+		if response.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Errorf("case: %v. got response status %v", idx, response.Status)
+		}
+	}
+}
+
+func TestGRPCUnavailable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close() // nothing listens on addr
+
+	exec, err := newGRPC(&common.GRPC{
+		Target: addr,
+		Method: "/grpc.health.v1.Health/Check",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := exec(context.Background(), execution{})
+	if a.success || !a.transportErr || a.statusCode != int(codes.Unavailable) {
+		t.Errorf("expected unavailable transport error, got %s", a.result)
+	}
+}
+
+func TestInvalidGRPC(t *testing.T) {
+	tasks := []*common.GRPC{
+		{Method: "/grpc.health.v1.Health/Check"},
+		{Target: "localhost:50051", Method: "grpc.health.v1.Health/Check"},
+		{Target: "localhost:50051", Method: "/grpc.health.v1.Health/"},
+		{Target: "localhost:50051", Method: "/grpc.health.v1.Health/Check", Metadata: map[string]string{"grpc-timeout": "1S"}},
+	}
+
+	for idx, task := range tasks {
+		if _, err := newGRPC(task); err == nil {
+			t.Errorf("case: %v. expected error", idx)
+		}
+	}
+}
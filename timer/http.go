@@ -2,6 +2,7 @@ package timer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,8 +11,10 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/signature"
 	"github.com/spf13/viper"
 )
 
@@ -19,24 +22,53 @@ import (
 var (
 	maxRequestBodySizeKey  string = "STEADY_HTTP_MAX_REQUEST_BODY_SIZE"
 	maxResponseBodySizeKey string = "STEADY_HTTP_MAX_RESPONSE_BODY_SIZE"
+	defaultTimeoutKey      string = "STEADY_HTTP_DEFAULT_TIMEOUT"
+	maxTimeoutKey          string = "STEADY_HTTP_MAX_TIMEOUT"
+	signingKeyKey          string = "STEADY_SIGNING_KEY"
 
 	maxRequestBodySize  int64
 	maxResponseBodySize int64
+	defaultTimeout      time.Duration
+	maxTimeout          time.Duration
 )
 
 func init() {
 	viper.SetDefault(maxRequestBodySizeKey, 1e6)
 	viper.SetDefault(maxResponseBodySizeKey, 1e6)
+	viper.SetDefault(defaultTimeoutKey, 10*time.Second)
+	viper.SetDefault(maxTimeoutKey, time.Minute)
 
 	maxRequestBodySize = viper.GetInt64(maxRequestBodySizeKey)
 	maxResponseBodySize = viper.GetInt64(maxResponseBodySizeKey)
+	defaultTimeout = viper.GetDuration(defaultTimeoutKey)
+	maxTimeout = viper.GetDuration(maxTimeoutKey)
+}
+
+// signRequest sets steady's headers on req, the signature is only set if there's a signing key.
+func signRequest(req *http.Request, body []byte, e execution) {
+	req.Header.Set(signature.ExecutionIDHeader, e.id)
+	if e.timerUUID != "" {
+		req.Header.Set(signature.TimerUUIDHeader, e.timerUUID)
+	}
+
+	// read on every request, so the key can be set after this package is initialized.
+	key := viper.GetString(signingKeyKey)
+	if key == "" || e.domain == "" {
+		return
+	}
+
+	// signed when the attempt started rather than when the fire did, so retries are within the receivers tolerance.
+	secret := signature.DomainSecret(key, e.domain)
+	req.Header.Set(signature.SignatureHeader, signature.Sign(secret, e.attemptTime, e.timerUUID, body))
 }
 
 // httpResponse for JSON marshalling
 type httpResponse struct {
-	StatusCode         int
-	Error, Proto, Body string
-	Headers            map[string][]string
+	StatusCode                    int
+	Error, ErrorKind, Proto, Body string
+	Headers                       map[string][]string
+	Success                       bool
+	Failure                       string // the success criterion that wasn't met.
 }
 
 func newHTTP(pb *common.HTTP) (execute, error) {
@@ -46,45 +78,128 @@ func newHTTP(pb *common.HTTP) (execute, error) {
 		return nil, errors.New("unknown method")
 	}
 
-	u, err := url.Parse(pb.Url)
+	if method == http.MethodHead && len(pb.Body) > 0 {
+		return nil, errors.New("HEAD request can't have a body")
+	}
+
+	if int64(len(pb.Body)) > maxRequestBodySize {
+		return nil, errors.New("request body too large")
+	}
+
+	timeout, err := getTimeout(pb.Timeout, defaultTimeout, maxTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("parsing url: %w", err)
+		return nil, err
 	}
 
-	if u.Host == "" {
-		return nil, errors.New("relative url not allowed")
+	criteria, err := newSuccessCriteria(pb.SuccessCriteria)
+	if err != nil {
+		return nil, fmt.Errorf("invalid success criteria: %w", err)
 	}
 
-	if int64(len(pb.Body)) > maxRequestBodySize {
-		return nil, errors.New("request body too large")
+	if pb.Template {
+		if err := validateHTTPTemplates(pb); err != nil {
+			return nil, err
+		}
+	}
+
+	// build a request with placeholder values, so invalid urls are rejected before the first execution.
+	// Every variable has a value, so templates in the host still render to a host.
+	now := time.Now()
+	placeholder := execution{
+		id:            "00000000-0000-0000-0000-000000000000-1",
+		timerUUID:     "00000000-0000-0000-0000-000000000000",
+		domain:        "domain",
+		number:        1,
+		scheduledTime: now,
+		fireTime:      now,
+		attemptTime:   now,
+	}
+	if _, _, err := newRequest(context.Background(), method, pb, placeholder); err != nil {
+		return nil, err
+	}
+
+	return getHTTPExecute(method, pb, timeout, criteria), nil
+}
+
+func validateHTTPTemplates(pb *common.HTTP) error {
+	if err := validateTemplate(pb.Url); err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+
+	for key, value := range pb.Headers {
+		if err := validateTemplate(value); err != nil {
+			return fmt.Errorf("header %v: %w", key, err)
+		}
+	}
+
+	if err := validateTemplate(string(pb.Body)); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+
+	return nil
+}
+
+// newRequest builds the request for an execution, rendering pb's templates if it has them.
+// It returns the request body as well, for signing.
+func newRequest(ctx context.Context, method string, pb *common.HTTP, e execution) (*http.Request, []byte, error) {
+	rawURL, headers, reqBody := pb.Url, pb.Headers, pb.Body
+	if pb.Template {
+		rawURL = renderURLTemplate(pb.Url, e)
+
+		headers = make(map[string]string, len(pb.Headers))
+		for key, value := range pb.Headers {
+			headers[key] = renderTemplate(value, e, nil)
+		}
+
+		if pb.Body != nil {
+			reqBody = []byte(renderTemplate(string(pb.Body), e, nil))
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing url: %w", err)
 	}
 
-	var body io.ReadCloser
-	if pb.Body != nil {
-		body = ioutil.NopCloser(bytes.NewReader(pb.Body))
+	if u.Host == "" {
+		return nil, nil, errors.New("relative url not allowed")
 	}
 
-	req, err := http.NewRequest(method, pb.Url, body)
+	var body io.Reader
+	if reqBody != nil {
+		body = bytes.NewReader(reqBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
 	if err != nil {
-		return nil, errors.New(err.Error())
+		return nil, nil, errors.New(err.Error())
 	}
 
-	req.ContentLength = int64(len(pb.Body))
+	req.ContentLength = int64(len(reqBody))
 
 	// merge headers
-	for key, value := range pb.Headers {
+	for key, value := range headers {
 		req.Header[key] = strings.Split(value, ",")
 	}
 
-	return getHTTPExecute(req, pb.SaveResponseBody), nil
+	return req, reqBody, nil
 }
 
-func getHTTPExecute(req *http.Request, saveResponseBody bool) execute {
-	return func() []byte {
+func getHTTPExecute(method string, pb *common.HTTP, timeout time.Duration, criteria *successCriteria) execute {
+	return func(ctx context.Context, e execution) attempt {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		req, reqBody, err := newRequest(ctx, method, pb, e)
+		if err != nil { // this should never happen, the request was validated by newHTTP.
+			fmt.Printf("building request: %v\n", err.Error())
+			return attempt{result: getErrorJSON(errorKindSystem, "steady system error.")}
+		}
+		signRequest(req, reqBody, e)
 
 		res, err := http.DefaultClient.Do(req)
 		if err != nil {
-			return getErrorJSON(err.Error())
+			return getErrorAttempt(ctx, err)
 		}
 
 		var result httpResponse
@@ -92,28 +207,45 @@ func getHTTPExecute(req *http.Request, saveResponseBody bool) execute {
 		result.StatusCode = res.StatusCode
 		result.Headers = res.Header
 
-		if saveResponseBody {
+		var body []byte
+		if pb.SaveResponseBody || criteria.needsBody() {
 			limitedReader := io.LimitedReader{R: res.Body, N: maxResponseBodySize}
 
-			body, err := ioutil.ReadAll(&limitedReader)
+			b, err := ioutil.ReadAll(&limitedReader)
 			if err != nil {
 				result.Error = err.Error()
+				result.ErrorKind = getErrorKind(ctx)
 
 			} else if limitedReader.N <= 0 { // limitedReader ran out of space.
 				result.Error = "response body size exceeded limit"
 
 			} else {
-				result.Body = string(body)
+				body = b
 			}
 		}
 		res.Body.Close()
 
+		if pb.SaveResponseBody {
+			result.Body = string(body)
+		}
+
+		if body == nil && criteria.needsBody() {
+			result.Failure = "response body unavailable: " + result.Error
+		} else {
+			result.Failure = criteria.check(res.StatusCode, body)
+		}
+		result.Success = result.Failure == ""
+
 		json, err := json.Marshal(result)
 		if err != nil { // this should never happen
 			fmt.Printf("marshalling result: %v\n", err.Error())
-			return getErrorJSON("steady system error.")
+			return attempt{result: getErrorJSON(errorKindSystem, "steady system error.")}
 		}
 
-		return json
+		return attempt{
+			result:     json,
+			statusCode: res.StatusCode,
+			success:    result.Success,
+		}
 	}
 }
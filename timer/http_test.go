@@ -0,0 +1,293 @@
+package timer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"github.com/nivista/steady/signature"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var httpTestCases = []struct {
+	method       common.Method
+	body         []byte
+	expectedBody string // body of the recorded response, the test server echoes the method and request body.
+}{
+	{common.Method_GET, nil, "GET "},
+	{common.Method_POST, []byte("hello"), "POST hello"},
+	{common.Method_PUT, []byte("hello"), "PUT hello"},
+	{common.Method_PATCH, []byte(`{"status":"done"}`), `PATCH {"status":"done"}`},
+	{common.Method_DELETE, nil, "DELETE "},
+	{common.Method_HEAD, nil, ""}, // HEAD responses have no body
+	{common.Method_OPTIONS, nil, "OPTIONS "},
+}
+
+func TestHTTPMethods(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + string(body)))
+	}))
+	defer server.Close()
+
+	for idx, tc := range httpTestCases {
+		exec, err := newHTTP(&common.HTTP{
+			Url:              server.URL,
+			Method:           tc.method,
+			Body:             tc.body,
+			SaveResponseBody: true,
+		})
+		if err != nil {
+			t.Errorf("case: %v. newHTTP: %v", idx, err)
+			continue
+		}
+
+		// fire twice to make sure the request body is resent.
+		for i := 0; i < 2; i++ {
+			var res httpResponse
+			if err := json.Unmarshal(exec(context.Background(), execution{}).result, &res); err != nil {
+				t.Errorf("case: %v. unmarshalling result: %v", idx, err)
+				continue
+			}
+
+			if res.StatusCode != http.StatusOK || res.Error != "" || !res.Success {
+				t.Errorf("case: %v. got status %v, error %q, failure %q", idx, res.StatusCode, res.Error, res.Failure)
+			}
+
+			if res.Body != tc.expectedBody {
+				t.Errorf("case: %v. got body %q, expected %q", idx, res.Body, tc.expectedBody)
+			}
+		}
+	}
+}
+
+func TestHTTPHeadWithBody(t *testing.T) {
+	_, err := newHTTP(&common.HTTP{
+		Url:    "http://example.com",
+		Method: common.Method_HEAD,
+		Body:   []byte("hello"),
+	})
+	if err == nil {
+		t.Error("expected error for HEAD request with a body")
+	}
+}
+
+func TestHTTPSignature(t *testing.T) {
+	viper.Set(signingKeyKey, "signing key")
+	defer viper.Set(signingKeyKey, "")
+
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	exec, err := newHTTP(&common.HTTP{
+		Url:    server.URL,
+		Method: common.Method_POST,
+		Body:   []byte("hello"),
+	})
+	if err != nil {
+		t.Fatalf("newHTTP: %v", err)
+	}
+
+	attemptTime := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	exec(context.Background(), execution{
+		id:          "timer-uuid-1",
+		timerUUID:   "timer-uuid",
+		domain:      "domain",
+		number:      1,
+		attemptTime: attemptTime,
+	})
+
+	r, body := <-requests, <-bodies
+	if id := r.Header.Get(signature.ExecutionIDHeader); id != "timer-uuid-1" {
+		t.Errorf("got execution id %q", id)
+	}
+
+	secret := signature.DomainSecret("signing key", "domain")
+	header := r.Header.Get(signature.SignatureHeader)
+	if expect := signature.Sign(secret, attemptTime, "timer-uuid", []byte("hello")); header != expect {
+		t.Errorf("got signature %q, expected %q signed at the attempt time", header, expect)
+	}
+
+	// the attempt time is in the past, so the age of the signature isn't checked.
+	err = signature.Verify(secret, header, r.Header.Get(signature.TimerUUIDHeader), body, 0)
+	if err != nil {
+		t.Errorf("verifying signature: %v", err)
+	}
+}
+
+func TestHTTPTemplate(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	exec, err := newHTTP(&common.HTTP{
+		Url:      server.URL + "/{{domain}}?at={{scheduled_time}}",
+		Method:   common.Method_POST,
+		Headers:  map[string]string{"Execution": "{{execution_number}}"},
+		Body:     []byte(`{"timer":"{{timer_uuid}}","scheduled":{{scheduled_time_unix}}}`),
+		Template: true,
+	})
+	if err != nil {
+		t.Fatalf("newHTTP: %v", err)
+	}
+
+	exec(context.Background(), execution{
+		timerUUID:     "timer-uuid",
+		domain:        "a b/c",
+		number:        2,
+		scheduledTime: time.Unix(60, 0),
+		fireTime:      time.Unix(61, 0),
+	})
+
+	r, body := <-requests, <-bodies
+	// the domain is a single path segment, escaped for the path rather than the query.
+	if r.URL.EscapedPath() != "/a%20b%2Fc" || r.URL.Query().Get("at") != "1970-01-01T00:01:00Z" {
+		t.Errorf("got url %v", r.URL)
+	}
+
+	if header := r.Header.Get("Execution"); header != "2" {
+		t.Errorf("got header %q", header)
+	}
+
+	if string(body) != `{"timer":"timer-uuid","scheduled":60}` {
+		t.Errorf("got body %s", body)
+	}
+}
+
+func TestHTTPHostTemplate(t *testing.T) {
+	// templates are validated with placeholder values, so a host that's only a variable is a host.
+	for _, u := range []string{"http://{{domain}}/hook", "https://{{timer_uuid}}.example.com", "http://{{domain}}:8080"} {
+		if _, err := newHTTP(&common.HTTP{Url: u, Template: true}); err != nil {
+			t.Errorf("case: %v. unexpected error: %v", u, err)
+		}
+	}
+}
+
+func TestHTTPInvalidTemplate(t *testing.T) {
+	var tests = []struct {
+		name string
+		pb   *common.HTTP
+	}{
+		{"url", &common.HTTP{Url: "http://example.com/{{unknown}}", Template: true}},
+		{"header", &common.HTTP{Url: "http://example.com", Headers: map[string]string{"a": "{{unknown}}"}, Template: true}},
+		{"body", &common.HTTP{Url: "http://example.com", Method: common.Method_POST, Body: []byte("{{unknown}}"), Template: true}},
+		{"relative url", &common.HTTP{Url: "{{domain}}", Template: true}},
+	}
+
+	for _, test := range tests {
+		if _, err := newHTTP(test.pb); err == nil {
+			t.Errorf("case: %v. expected error", test.name)
+		}
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	exec, err := newHTTP(&common.HTTP{
+		Url:     server.URL,
+		Timeout: durationpb.New(10 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("newHTTP: %v", err)
+	}
+
+	var res errorResult
+	if err := json.Unmarshal(exec(context.Background(), execution{}).result, &res); err != nil {
+		t.Fatalf("unmarshalling result: %v", err)
+	}
+
+	if res.ErrorKind != errorKindTimeout {
+		t.Errorf("got error kind %q, expected %q", res.ErrorKind, errorKindTimeout)
+	}
+}
+
+func TestHTTPInvalidTimeout(t *testing.T) {
+	for _, timeout := range []time.Duration{-time.Second, 0, maxTimeout + time.Second} {
+		_, err := newHTTP(&common.HTTP{
+			Url:     "http://example.com",
+			Timeout: durationpb.New(timeout),
+		})
+		if err == nil {
+			t.Errorf("expected error for timeout %v", timeout)
+		}
+	}
+}
+
+func TestStopCancelsHTTP(t *testing.T) {
+	requested, unblock := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	fc := clockwork.NewFakeClockAt(time.Unix(0, 0))
+	tmr, err := New(&messaging.Create{
+		Task: &common.Task{
+			Task: &common.Task_Http{
+				Http: &common.HTTP{
+					Url:     server.URL,
+					Timeout: durationpb.New(time.Minute),
+				},
+			},
+		},
+		Schedule: &common.Schedule{
+			Spec:      &common.Schedule_Cron{Cron: "@every 1s"},
+			StartTime: timestamppb.New(time.Unix(1, 0)),
+		},
+	}, func(*messaging.Execute) {
+		t.Error("unexpected execution recorded after stop")
+	}, func() {}, fc, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tmr.Start()
+	fc.BlockUntil(1)
+	fc.Advance(time.Second)
+
+	select {
+	case <-requested:
+	case <-time.After(time.Second):
+		t.Fatal("expected request")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		tmr.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("Stop didn't cancel the in flight request")
+	}
+}
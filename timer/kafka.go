@@ -0,0 +1,110 @@
+package timer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/nivista/steady/.gen/protos/common"
+)
+
+type (
+	// Producer sends the messages of kafka publish tasks. sarama.SyncProducer implements it.
+	Producer interface {
+		SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error)
+	}
+
+	// kafkaResponse for JSON marshalling
+	kafkaResponse struct {
+		Topic            string
+		Partition        int32
+		Offset           int64
+		Error, ErrorKind string
+		Success          bool
+	}
+)
+
+var validTopic = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// ErrForbiddenTopic is returned by producers for topics tasks can't publish to, attempts that get it aren't retried.
+var ErrForbiddenTopic = errors.New("can't publish to topic")
+
+// newKafkaPublish only allows the domain's topics, which are prefixed with the domain and a dot.
+// This keeps tasks from publishing to other domains' topics, kafka's internal topics and steady's own topics.
+func newKafkaPublish(pb *common.KafkaPublish, domain string, producer Producer) (execute, error) {
+	if !validTopic.MatchString(pb.Topic) {
+		return nil, errors.New("invalid topic")
+	}
+
+	if domain == "" || !strings.HasPrefix(pb.Topic, domain+".") || len(pb.Topic) == len(domain)+1 {
+		return nil, fmt.Errorf("topic must be prefixed with the domain, like %v.name", domain)
+	}
+
+	if int64(len(pb.Key)+len(pb.Value)) > maxRequestBodySize {
+		return nil, errors.New("message too large")
+	}
+
+	return getKafkaPublishExecute(pb, producer), nil
+}
+
+func getKafkaPublishExecute(pb *common.KafkaPublish, producer Producer) execute {
+	return func(ctx context.Context, e execution) attempt {
+		if producer == nil {
+			return attempt{result: getErrorJSON(errorKindSystem, "no producer for kafka publish tasks.")}
+		}
+
+		msg := &sarama.ProducerMessage{
+			Topic: pb.Topic,
+		}
+
+		if pb.Key != nil {
+			msg.Key = sarama.ByteEncoder(pb.Key)
+		}
+
+		if pb.Value != nil {
+			msg.Value = sarama.ByteEncoder(pb.Value)
+		}
+
+		for key, value := range pb.Headers {
+			msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+		}
+
+		var result kafkaResponse
+		result.Topic = pb.Topic
+
+		done := make(chan error, 1)
+		go func() {
+			var err error
+			result.Partition, result.Offset, err = producer.SendMessage(msg)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			if errors.Is(err, ErrForbiddenTopic) {
+				return attempt{result: getErrorJSON(errorKindSystem, err.Error())}
+			} else if err != nil {
+				return getErrorAttempt(ctx, err)
+			}
+
+		case <-ctx.Done(): // the message may still be published.
+			return getErrorAttempt(ctx, ctx.Err())
+		}
+		result.Success = true
+
+		json, err := json.Marshal(result)
+		if err != nil { // this should never happen
+			fmt.Printf("marshalling result: %v\n", err.Error())
+			return attempt{result: getErrorJSON(errorKindSystem, "steady system error.")}
+		}
+
+		return attempt{
+			result:  json,
+			success: true,
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package timer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/nivista/steady/.gen/protos/common"
+)
+
+func TestKafkaPublish(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	producer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(val []byte) error {
+		if string(val) != "run" {
+			return errors.New("unexpected value " + string(val))
+		}
+		return nil
+	})
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+	producer.ExpectSendMessageAndFail(fmt.Errorf("%w create", ErrForbiddenTopic))
+
+	exec, err := newKafkaPublish(&common.KafkaPublish{
+		Topic:   "acme.pipelines",
+		Key:     []byte("nightly"),
+		Value:   []byte("run"),
+		Headers: map[string]string{"source": "steady"},
+	}, "acme", producer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expectedOffset := range []int64{1, 2} {
+		a := exec(context.Background(), execution{})
+		if !a.success {
+			t.Fatalf("expected success, got %s", a.result)
+		}
+
+		var res kafkaResponse
+		if err := json.Unmarshal(a.result, &res); err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Topic != "acme.pipelines" || res.Offset != expectedOffset {
+			t.Errorf("got topic %v offset %v, expected offset %v", res.Topic, res.Offset, expectedOffset)
+		}
+	}
+
+	a := exec(context.Background(), execution{})
+	if a.success || !a.transportErr {
+		t.Errorf("expected transport error, got %s", a.result)
+	}
+
+	// forbidden topics aren't retried.
+	a = exec(context.Background(), execution{})
+	if a.success || a.transportErr {
+		t.Errorf("expected error that isn't a transport error, got %s", a.result)
+	}
+}
+
+func TestInvalidKafkaPublish(t *testing.T) {
+	tasks := []*common.KafkaPublish{
+		{Topic: ""},
+		{Topic: "acme.has spaces"},
+		{Topic: "acme.big", Value: make([]byte, maxRequestBodySize+1)},
+		{Topic: "pipelines"},
+		{Topic: "acme."},
+		{Topic: "acmecorp.pipelines"},
+		{Topic: "other.pipelines"},
+		{Topic: "create"},
+		{Topic: "execute"},
+		{Topic: "__consumer_offsets"},
+	}
+
+	for idx, task := range tasks {
+		if _, err := newKafkaPublish(task, "acme", nil); err == nil {
+			t.Errorf("case: %v. expected error", idx)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package timer
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxLabels is the most labels a timer can have.
+const maxLabels = 64
+
+// maxLabelValueLength is the longest a label value can be, in bytes.
+const maxLabelValueLength = 256
+
+var validLabelKey = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,63}$`)
+
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("at most %v labels are allowed", maxLabels)
+	}
+
+	for key, value := range labels {
+		if !validLabelKey.MatchString(key) {
+			return fmt.Errorf("label key %q must be 1 to 63 letters, digits, underscores, dashes or dots", key)
+		}
+
+		if len(value) > maxLabelValueLength {
+			return fmt.Errorf("value of label %v exceeds maximum length of %v", key, maxLabelValueLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package timer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateLabels(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= maxLabels; i++ {
+		tooMany[strings.Repeat("a", i+1)] = ""
+	}
+
+	var tests = []struct {
+		name   string
+		labels map[string]string
+		valid  bool
+	}{
+		{"none", nil, true},
+		{"valid", map[string]string{"env": "prod", "team.name_1-a": ""}, true},
+		{"empty key", map[string]string{"": "prod"}, false},
+		{"long key", map[string]string{strings.Repeat("a", 64): ""}, false},
+		{"invalid key", map[string]string{"env=": "prod"}, false},
+		{"long value", map[string]string{"env": strings.Repeat("a", maxLabelValueLength+1)}, false},
+		{"too many", tooMany, false},
+	}
+
+	for _, test := range tests {
+		err := validateLabels(test.labels)
+		if test.valid && err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("case: %v. expected error", test.name)
+		}
+	}
+}
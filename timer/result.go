@@ -0,0 +1,78 @@
+package timer
+
+import (
+	"encoding/json"
+)
+
+type (
+	// Result is a decoded execution result.
+	Result struct {
+		Attempts        []Attempt // in order, empty if the fire was skipped.
+		SkippedCalendar string
+	}
+
+	// Attempt is a decoded attempt of an execution.
+	Attempt struct {
+		StatusCode                int
+		Error, ErrorKind, Failure string
+		Body                      []byte
+	}
+
+	// storedResult has the fields of every kind of result.
+	storedResult struct {
+		Attempts []json.RawMessage
+		Skipped  bool
+		Calendar string
+	}
+
+	// storedAttempt has the fields of every kind of attempt.
+	storedAttempt struct {
+		StatusCode                int
+		Error, ErrorKind, Failure string
+		Body                      string // http responses.
+		Response                  []byte // grpc responses.
+	}
+)
+
+// ParseResult decodes the result of an execution, as recorded on the execute topic.
+func ParseResult(result []byte) (Result, error) {
+	if len(result) == 0 {
+		return Result{}, nil
+	}
+
+	var stored storedResult
+	if err := json.Unmarshal(result, &stored); err != nil {
+		return Result{}, err
+	}
+
+	if stored.Skipped {
+		return Result{SkippedCalendar: stored.Calendar}, nil
+	}
+
+	attempts := stored.Attempts
+	if attempts == nil { // executions without a retry policy have a single attempt.
+		attempts = []json.RawMessage{result}
+	}
+
+	var res Result
+	for _, a := range attempts {
+		var stored storedAttempt
+		if err := json.Unmarshal(a, &stored); err != nil {
+			return Result{}, err
+		}
+
+		attempt := Attempt{
+			StatusCode: stored.StatusCode,
+			Error:      stored.Error,
+			ErrorKind:  stored.ErrorKind,
+			Failure:    stored.Failure,
+			Body:       stored.Response,
+		}
+		if stored.Body != "" {
+			attempt.Body = []byte(stored.Body)
+		}
+		res.Attempts = append(res.Attempts, attempt)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,41 @@
+package timer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseResult(t *testing.T) {
+	httpResult, _ := json.Marshal(httpResponse{StatusCode: 500, Body: "oops", Failure: "status code 500"})
+	grpcResult, _ := json.Marshal(grpcResponse{StatusCode: 0, Response: []byte{1, 2}, Success: true})
+	retried, _ := json.Marshal(retryResult{Attempts: []json.RawMessage{httpResult, getErrorJSON(errorKindTimeout, "deadline exceeded")}})
+
+	var tests = []struct {
+		name     string
+		result   []byte
+		expected Result
+	}{
+		{"empty", nil, Result{}},
+		{"http", httpResult, Result{Attempts: []Attempt{{StatusCode: 500, Body: []byte("oops"), Failure: "status code 500"}}}},
+		{"grpc", grpcResult, Result{Attempts: []Attempt{{Body: []byte{1, 2}}}}},
+		{"retried", retried, Result{Attempts: []Attempt{
+			{StatusCode: 500, Body: []byte("oops"), Failure: "status code 500"},
+			{Error: "deadline exceeded", ErrorKind: errorKindTimeout},
+		}}},
+		{"skipped", getSkippedJSON("holidays"), Result{SkippedCalendar: "holidays"}},
+	}
+
+	for _, test := range tests {
+		res, err := ParseResult(test.result)
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+		} else if !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("case: %v. got %+v, expected %+v", test.name, res, test.expected)
+		}
+	}
+
+	if _, err := ParseResult([]byte("not json")); err == nil {
+		t.Error("expected error for invalid result")
+	}
+}
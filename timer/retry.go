@@ -0,0 +1,179 @@
+package timer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nivista/steady/.gen/protos/common"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// upper limit for the number of attempts of a single execution.
+var (
+	maxAttemptsKey string = "STEADY_RETRY_MAX_ATTEMPTS"
+
+	maxAttempts int
+)
+
+const (
+	defaultInitialBackoff = time.Second
+	defaultMultiplier     = 2
+)
+
+func init() {
+	viper.SetDefault(maxAttemptsKey, 10)
+
+	maxAttempts = viper.GetInt(maxAttemptsKey)
+}
+
+type (
+	// retryPolicy is a validated common.RetryPolicy.
+	retryPolicy struct {
+		maxAttempts     int
+		initialBackoff  time.Duration
+		multiplier      float64
+		maxBackoff      time.Duration // no limit if zero.
+		statusCodes     map[int]bool
+		transportErrors bool
+	}
+
+	// retryResult for JSON marshalling
+	retryResult struct {
+		Attempts []json.RawMessage
+	}
+)
+
+// newRetryPolicy returns nil if pb is nil, meaning executions are attempted once.
+func newRetryPolicy(pb *common.RetryPolicy) (*retryPolicy, error) {
+	if pb == nil {
+		return nil, nil
+	}
+
+	if pb.MaxAttempts < 1 {
+		return nil, errors.New("max attempts must be positive")
+	}
+
+	if int(pb.MaxAttempts) > maxAttempts {
+		return nil, fmt.Errorf("max attempts exceeds maximum of %v", maxAttempts)
+	}
+
+	initialBackoff, err := getBackoff(pb.InitialBackoff, defaultInitialBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("invalid initial backoff: %w", err)
+	}
+
+	maxBackoff, err := getBackoff(pb.MaxBackoff, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max backoff: %w", err)
+	}
+
+	if maxBackoff != 0 && maxBackoff < initialBackoff {
+		return nil, errors.New("max backoff is less than initial backoff")
+	}
+
+	multiplier := pb.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultMultiplier
+	}
+
+	if multiplier < 1 {
+		return nil, errors.New("multiplier must be at least 1")
+	}
+
+	statusCodes := make(map[int]bool, len(pb.RetryableStatusCodes))
+	for _, code := range pb.RetryableStatusCodes {
+		if code <= 0 {
+			return nil, fmt.Errorf("invalid status code %v", code)
+		}
+		statusCodes[int(code)] = true
+	}
+
+	return &retryPolicy{
+		maxAttempts:     int(pb.MaxAttempts),
+		initialBackoff:  initialBackoff,
+		multiplier:      multiplier,
+		maxBackoff:      maxBackoff,
+		statusCodes:     statusCodes,
+		transportErrors: pb.RetryTransportErrors,
+	}, nil
+}
+
+func (p *retryPolicy) retryable(a attempt) bool {
+	if a.success {
+		return false
+	}
+
+	// gRPC attempts that fail without a response still have a status code, like UNAVAILABLE or DEADLINE_EXCEEDED,
+	// so they're retried if either their status code or transport errors are retryable.
+	if a.transportErr && p.transportErrors {
+		return true
+	}
+	return p.statusCodes[a.statusCode]
+}
+
+func (p *retryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	next := time.Duration(float64(backoff) * p.multiplier)
+	if p.maxBackoff != 0 && next > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return next
+}
+
+// executeWithRetries executes the timers task, retrying according to its retry policy.
+// The returned attempt is the last one, with the results of every attempt.
+func (t *timer) executeWithRetries(e execution) attempt {
+	if t.retry == nil {
+		e.attemptTime = t.clock.Now()
+		return t.execute(t.ctx, e)
+	}
+
+	var a attempt
+	var attempts []json.RawMessage
+	backoff := t.retry.initialBackoff
+
+Retry:
+	for {
+		e.attemptTime = t.clock.Now()
+		a = t.execute(t.ctx, e)
+		attempts = append(attempts, a.result)
+
+		if len(attempts) >= t.retry.maxAttempts || !t.retry.retryable(a) {
+			break
+		}
+
+		select {
+		case <-t.clock.After(backoff):
+			backoff = t.retry.nextBackoff(backoff)
+		case <-t.ctx.Done():
+			break Retry
+		}
+	}
+
+	json, err := json.Marshal(retryResult{Attempts: attempts})
+	if err != nil { // this should never happen
+		fmt.Printf("marshalling retry result: %v\n", err.Error())
+		return attempt{result: getErrorJSON(errorKindSystem, "steady system error.")}
+	}
+
+	a.result = json
+	return a
+}
+
+func getBackoff(pb *durationpb.Duration, def time.Duration) (time.Duration, error) {
+	if pb == nil {
+		return def, nil
+	}
+
+	if err := pb.CheckValid(); err != nil {
+		return 0, err
+	}
+
+	if pb.AsDuration() <= 0 {
+		return 0, errors.New("backoff must be positive")
+	}
+
+	return pb.AsDuration(), nil
+}
@@ -0,0 +1,188 @@
+package timer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/nivista/steady/.gen/protos/common"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var retryTestCases = []struct {
+	policy           *common.RetryPolicy
+	attempts         []attempt       // attempts returned by the task, in order.
+	expectedBackoffs []time.Duration // expected waits between attempts.
+}{
+	// No policy, attempted once.
+	{
+		policy:   nil,
+		attempts: []attempt{{statusCode: 503}},
+	},
+
+	// Retries retryable status codes with exponential backoff until success.
+	{
+		policy: &common.RetryPolicy{
+			MaxAttempts:          5,
+			InitialBackoff:       durationpb.New(time.Second),
+			Multiplier:           2,
+			RetryableStatusCodes: []int32{503},
+		},
+		attempts:         []attempt{{statusCode: 503}, {statusCode: 503}, {statusCode: 200, success: true}},
+		expectedBackoffs: []time.Duration{time.Second, 2 * time.Second},
+	},
+
+	// Backoff is capped and attempts are limited.
+	{
+		policy: &common.RetryPolicy{
+			MaxAttempts:          3,
+			InitialBackoff:       durationpb.New(time.Second),
+			Multiplier:           10,
+			MaxBackoff:           durationpb.New(5 * time.Second),
+			RetryTransportErrors: true,
+		},
+		attempts:         []attempt{{transportErr: true}, {transportErr: true}, {transportErr: true}},
+		expectedBackoffs: []time.Duration{time.Second, 5 * time.Second},
+	},
+
+	// Non retryable status code.
+	{
+		policy: &common.RetryPolicy{
+			MaxAttempts:          3,
+			RetryableStatusCodes: []int32{503},
+		},
+		attempts: []attempt{{statusCode: 500}},
+	},
+
+	// Transport errors aren't retried unless asked for.
+	{
+		policy: &common.RetryPolicy{
+			MaxAttempts:          3,
+			RetryableStatusCodes: []int32{503},
+		},
+		attempts: []attempt{{transportErr: true}},
+	},
+
+	// gRPC transport errors are retried by their status code, UNAVAILABLE and DEADLINE_EXCEEDED.
+	{
+		policy: &common.RetryPolicy{
+			MaxAttempts:          3,
+			InitialBackoff:       durationpb.New(time.Second),
+			Multiplier:           2,
+			RetryableStatusCodes: []int32{14, 4},
+		},
+		attempts:         []attempt{{transportErr: true, statusCode: 14}, {transportErr: true, statusCode: 4}, {statusCode: 0, success: true}},
+		expectedBackoffs: []time.Duration{time.Second, 2 * time.Second},
+	},
+
+	// gRPC transport errors with other status codes are retried if transport errors are.
+	{
+		policy: &common.RetryPolicy{
+			MaxAttempts:          2,
+			InitialBackoff:       durationpb.New(time.Second),
+			RetryTransportErrors: true,
+		},
+		attempts:         []attempt{{transportErr: true, statusCode: 14}, {transportErr: true, statusCode: 14}},
+		expectedBackoffs: []time.Duration{time.Second},
+	},
+}
+
+func TestRetry(t *testing.T) {
+	for idx, tc := range retryTestCases {
+		retry, err := newRetryPolicy(tc.policy)
+		if err != nil {
+			t.Errorf("case: %v. newRetryPolicy: %v", idx, err)
+			continue
+		}
+
+		var calls int
+		var attemptTimes []time.Time
+		fc := clockwork.NewFakeClockAt(time.Unix(0, 0))
+		ctx, cancel := context.WithCancel(context.Background())
+		tmr := &timer{
+			execute: func(_ context.Context, e execution) attempt {
+				attemptTimes = append(attemptTimes, e.attemptTime)
+				a := tc.attempts[calls]
+				a.result = []byte(`{}`)
+				calls++
+				return a
+			},
+			retry:  retry,
+			clock:  fc,
+			ctx:    ctx,
+			cancel: cancel,
+		}
+
+		results := make(chan attempt)
+		go func() {
+			results <- tmr.executeWithRetries(execution{})
+		}()
+
+		for _, backoff := range tc.expectedBackoffs {
+			fc.BlockUntil(1)
+			fc.Advance(backoff - time.Nanosecond)
+			select {
+			case <-results:
+				t.Errorf("case: %v. retried before backoff %v", idx, backoff)
+			case <-time.After(10 * time.Millisecond):
+			}
+			fc.Advance(time.Nanosecond)
+		}
+
+		var res attempt
+		select {
+		case res = <-results:
+		case <-time.After(time.Second):
+			t.Errorf("case: %v. expected result", idx)
+			continue
+		}
+
+		if calls != len(tc.attempts) {
+			t.Errorf("case: %v. got %v attempts, expected %v", idx, calls, len(tc.attempts))
+		}
+
+		// the attempt time is read from the timers clock, after the backoffs before it.
+		expectedTime := time.Unix(0, 0)
+		for i, attemptTime := range attemptTimes {
+			if i > 0 && i <= len(tc.expectedBackoffs) {
+				expectedTime = expectedTime.Add(tc.expectedBackoffs[i-1])
+			}
+			if !attemptTime.Equal(expectedTime) {
+				t.Errorf("case: %v. got attempt %v at %v, expected %v", idx, i, attemptTime, expectedTime)
+			}
+		}
+
+		if tc.policy == nil {
+			continue
+		}
+
+		var retryRes retryResult
+		if err := json.Unmarshal(res.result, &retryRes); err != nil {
+			t.Errorf("case: %v. unmarshalling result: %v", idx, err)
+			continue
+		}
+
+		if len(retryRes.Attempts) != len(tc.attempts) {
+			t.Errorf("case: %v. got %v attempts in result, expected %v", idx, len(retryRes.Attempts), len(tc.attempts))
+		}
+	}
+}
+
+func TestInvalidRetryPolicy(t *testing.T) {
+	policies := []*common.RetryPolicy{
+		{MaxAttempts: 0},
+		{MaxAttempts: int32(maxAttempts) + 1},
+		{MaxAttempts: 2, Multiplier: 0.5},
+		{MaxAttempts: 2, InitialBackoff: durationpb.New(-time.Second)},
+		{MaxAttempts: 2, InitialBackoff: durationpb.New(time.Minute), MaxBackoff: durationpb.New(time.Second)},
+		{MaxAttempts: 2, RetryableStatusCodes: []int32{-1}},
+	}
+
+	for idx, policy := range policies {
+		if _, err := newRetryPolicy(policy); err == nil {
+			t.Errorf("case: %v. expected error", idx)
+		}
+	}
+}
@@ -1,54 +1,433 @@
 package timer
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"strings"
 	"time"
 
 	"github.com/nivista/steady/.gen/protos/common"
 	"github.com/robfig/cron"
+	"github.com/spf13/viper"
+	"github.com/teambition/rrule-go"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-type schedule func(prog progress, now time.Time) *time.Time
+// schedule returns the next fire, or nil if there are no more fires.
+type schedule func(prog progress, now time.Time) *fire
 
-var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+type (
+	// fire is a fire of a timer, a fire at a time before now is missed, and should happen as soon as possible.
+	fire struct {
+		slot time.Time // the time in the schedule the fire is for.
+		at   time.Time // when the fire should happen, the slot shifted by the schedules jitter.
+
+		skipped string // the calendar that excludes the fire, empty if it isn't excluded.
+	}
+
+	// oneShot is a cron.Schedule that fires once, at its time.
+	oneShot time.Time
+
+	// interval is a cron.Schedule that fires at start, then every period after it.
+	interval struct {
+		start  time.Time
+		period time.Duration
+	}
+
+	// recurrence is a cron.Schedule that fires on the occurrences of an RFC 5545 recurrence set.
+	recurrence struct {
+		set *rrule.Set
+	}
+)
+
+var (
+	parser        = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	secondsParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+)
 
 const rollback = -1 * time.Nanosecond
 
-func newSchedule(p *common.Schedule) (schedule, error) {
-	sched, err := parser.Parse(p.Cron)
+// upper limit for the jitter of a schedule.
+var (
+	maxJitterKey string = "STEADY_MAX_JITTER"
+
+	maxJitter time.Duration
+)
+
+func init() {
+	viper.SetDefault(maxJitterKey, time.Hour)
+
+	maxJitter = viper.GetDuration(maxJitterKey)
+}
+
+// newSchedule creates the schedule of a timer, calendars has to contain every calendar the schedule refers to.
+// The timer UUID seeds its jitter.
+func newSchedule(p *common.Schedule, calendars []*common.Calendar, timerUUID string) (schedule, error) {
+	switch p.Spec.(type) {
+	case *common.Schedule_Cron, *common.Schedule_Rrule:
+	default:
+		if p.Timezone != "" {
+			return nil, fmt.Errorf("timezone is only allowed with cron or rrule")
+		}
+	}
+
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	sched, err := newSpec(p, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.MaxExecutions < 0 {
+		return nil, fmt.Errorf("max executions can't be negative")
+	}
+
+	if _, ok := common.MisfirePolicy_name[int32(p.MisfirePolicy)]; !ok {
+		return nil, fmt.Errorf("unknown misfire policy")
+	}
+
+	var graceWindow time.Duration
+	if p.MisfirePolicy == common.MisfirePolicy_MISFIRE_POLICY_GRACE_WINDOW {
+		if p.GraceWindow.CheckValid() != nil || p.GraceWindow.AsDuration() <= 0 {
+			return nil, fmt.Errorf("grace window policy requires a positive grace window")
+		}
+		graceWindow = p.GraceWindow.AsDuration()
+	} else if p.GraceWindow != nil {
+		return nil, fmt.Errorf("grace window is only allowed with the grace window policy")
+	}
+
+	exclusions, err := newCalendars(p.Calendars, calendars)
 	if err != nil {
-		return nil, fmt.Errorf("invalid cron: %w", err)
+		return nil, err
+	}
+
+	var jitter time.Duration
+	if p.Jitter != nil {
+		if p.Jitter.CheckValid() != nil || p.Jitter.AsDuration() < 0 {
+			return nil, fmt.Errorf("jitter can't be negative")
+		}
+
+		if jitter = p.Jitter.AsDuration(); jitter > maxJitter {
+			return nil, fmt.Errorf("jitter exceeds maximum of %v", maxJitter)
+		}
 	}
 
-	if p.StartTime == nil {
-		return nil, fmt.Errorf("start time required")
+	// next returns the first fire after t, in the timers location so wall clock times follow its DST changes.
+	next := func(t time.Time) time.Time {
+		nextFire := sched.Next(t.In(loc))
+
+		// wall clock times that repeat when clocks are set back only fire the first time.
+		if _, ok := sched.(*cron.SpecSchedule); ok {
+			for isRepeatedWallClock(nextFire) {
+				nextFire = sched.Next(nextFire)
+			}
+
+			// wall clock times skipped when clocks are set forward fire when they're set forward.
+			if skipped := getSkippedWallClockFire(sched, t.In(loc), nextFire); !skipped.IsZero() {
+				return skipped
+			}
+		}
+		return nextFire
 	}
 
-	return func(prog progress, now time.Time) *time.Time {
+	// fires could happen out of order if the jitter shifted them past the next slot.
+	if jitter > 0 {
+		if period := getShortestPeriod(next, p.StartTime.AsTime()); period != 0 && jitter >= period {
+			return nil, fmt.Errorf("jitter must be less than the %v between fires", period)
+		}
+	}
+
+	// newFire returns the fire for a slot, shifted by an offset that is the same on every node.
+	newFire := func(slot time.Time) *fire {
+		return &fire{slot: slot, at: slot.Add(getJitterOffset(timerUUID, slot, jitter))}
+	}
+
+	// firstFireAfter returns the first fire that happens at or after t, or nil if there isn't one.
+	firstFireAfter := func(t time.Time) *fire {
+		for slot := next(t.Add(-jitter).Add(rollback)); !slot.IsZero(); slot = next(slot) {
+			if f := newFire(slot); !f.at.Before(t) {
+				return f
+			}
+		}
+		return nil
+	}
+
+	return func(prog progress, now time.Time) *fire {
 		// check executions condition
-		if p.MaxExecutions != common.Executions_INFINITE && prog.completedExecutions >= int32(p.MaxExecutions) {
+		if p.MaxExecutions != 0 && prog.completedExecutions >= p.MaxExecutions {
 			return nil
 		}
 
 		var beforeNextFire time.Time
-		if prog.lastExecution == nil { // this is the first execution
-			beforeNextFire = p.StartTime.AsTime().Add(rollback)
-		} else {
+		if prog.lastScheduled != nil {
+			beforeNextFire = *prog.lastScheduled
+		} else if prog.lastExecution != nil { // progress from before scheduled times were recorded.
 			beforeNextFire = *prog.lastExecution
+		} else { // this is the first execution
+			beforeNextFire = p.StartTime.AsTime().Add(rollback)
 		}
 
 		// find next fire
-		nextFire := sched.Next(beforeNextFire)
+		slot := next(beforeNextFire)
+		if slot.IsZero() { // the spec has no more fires
+			return nil
+		}
+		nextFire := newFire(slot)
+
+		if nextFire.at.Before(now) { // missed fire
+			switch p.MisfirePolicy {
+			case common.MisfirePolicy_MISFIRE_POLICY_FIRE_ONCE:
+				nextFire = &fire{slot: now, at: now} // compensate for every missed fire at once
+
+			case common.MisfirePolicy_MISFIRE_POLICY_SKIP:
+				if nextFire = firstFireAfter(now); nextFire == nil {
+					return nil
+				}
 
-		if nextFire.Before(now) { // missed fire
-			nextFire = now // compensate for missed fire
+			case common.MisfirePolicy_MISFIRE_POLICY_GRACE_WINDOW:
+				if earliest := now.Add(-graceWindow); nextFire.at.Before(earliest) {
+					if nextFire = firstFireAfter(earliest); nextFire == nil {
+						return nil
+					}
+				}
+
+			case common.MisfirePolicy_MISFIRE_POLICY_FIRE_ALL: // every missed fire happens in order.
+			}
 		}
 
 		// check stoptime condition
-		if p.StopTime != nil && nextFire.After(p.StopTime.AsTime()) {
+		if p.StopTime != nil && nextFire.at.After(p.StopTime.AsTime()) {
 			return nil
 		}
 
-		return &nextFire
+		for _, cal := range exclusions {
+			if cal.excludes(nextFire.at) {
+				nextFire.skipped = cal.name
+				break
+			}
+		}
+
+		return nextFire
 	}, nil
 }
+
+// periodSamples is how many fires getShortestPeriod looks at.
+const periodSamples = 100
+
+// getShortestPeriod returns the shortest time between the first fires after start, or zero if there's at most one.
+func getShortestPeriod(next func(time.Time) time.Time, start time.Time) time.Duration {
+	var shortest time.Duration
+	prev := next(start.Add(rollback))
+	for i := 1; i < periodSamples && !prev.IsZero(); i++ {
+		slot := next(prev)
+		if slot.IsZero() {
+			break
+		}
+
+		if period := slot.Sub(prev); shortest == 0 || period < shortest {
+			shortest = period
+		}
+		prev = slot
+	}
+	return shortest
+}
+
+// getJitterOffset returns a pseudo random offset in [0, jitter), derived from the timer UUID and slot.
+func getJitterOffset(timerUUID string, slot time.Time, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(timerUUID))
+	binary.Write(h, binary.BigEndian, slot.UnixNano())
+	return time.Duration(h.Sum64() % uint64(jitter))
+}
+
+// newSpec returns the cron.Schedule for the spec of a schedule, a zero time from Next means there are no more fires.
+// Local times in the spec are in loc.
+func newSpec(p *common.Schedule, loc *time.Location) (cron.Schedule, error) {
+	switch spec := p.Spec.(type) {
+	case *common.Schedule_Cron:
+		sched, err := parseCron(spec.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron: %w", err)
+		}
+
+		if p.StartTime == nil {
+			return nil, fmt.Errorf("start time required")
+		}
+		return sched, nil
+
+	case *common.Schedule_At:
+		if err := spec.At.CheckValid(); err != nil {
+			return nil, fmt.Errorf("invalid at: %w", err)
+		}
+		return oneShot(spec.At.AsTime()), nil
+
+	case *common.Schedule_Interval:
+		if err := spec.Interval.CheckValid(); err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+
+		if spec.Interval.AsDuration() <= 0 {
+			return nil, fmt.Errorf("interval must be positive")
+		}
+
+		if p.StartTime == nil {
+			return nil, fmt.Errorf("start time required")
+		}
+		return interval{start: p.StartTime.AsTime(), period: spec.Interval.AsDuration()}, nil
+
+	case *common.Schedule_Rrule:
+		set, err := parseRRule(spec.Rrule, p.StartTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrule: %w", err)
+		}
+		return recurrence{set}, nil
+
+	default:
+		return nil, fmt.Errorf("cron, at, interval or rrule required")
+	}
+}
+
+// Next returns the time of the one shot, or the zero time if t isn't before it.
+func (o oneShot) Next(t time.Time) time.Time {
+	if t.Before(time.Time(o)) {
+		return time.Time(o)
+	}
+	return time.Time{}
+}
+
+// Next returns the first fire of the interval after t.
+func (i interval) Next(t time.Time) time.Time {
+	if t.Before(i.start) {
+		return i.start
+	}
+	return i.start.Add((t.Sub(i.start)/i.period + 1) * i.period)
+}
+
+// Next returns the first occurrence of the recurrence after t.
+func (r recurrence) Next(t time.Time) time.Time {
+	return r.set.After(t, false)
+}
+
+// parseRRule parses RFC 5545 recurrence lines, the DTSTART line is optional if there is a start time.
+func parseRRule(spec string, start *timestamppb.Timestamp, loc *time.Location) (*rrule.Set, error) {
+	var lines []string
+	var hasDTStart bool
+	for _, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		name := strings.ToUpper(line)
+		if idx := strings.IndexAny(name, ";:"); idx > 0 {
+			name = name[:idx]
+		}
+
+		switch name {
+		case "DTSTART":
+			if len(lines) > 0 {
+				return nil, errors.New("DTSTART must be the first line")
+			}
+			hasDTStart = true
+		case "RRULE", "RDATE", "EXDATE":
+		default:
+			return nil, fmt.Errorf("unsupported line %q", line)
+		}
+		lines = append(lines, line)
+	}
+
+	set, err := rrule.StrSliceToRRuleSetInLoc(lines, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if set.GetRRule() == nil && len(set.GetRDate()) == 0 {
+		return nil, errors.New("RRULE or RDATE required")
+	}
+
+	if !hasDTStart {
+		if start == nil {
+			return nil, errors.New("DTSTART or start time required")
+		}
+		set.DTStart(start.AsTime().In(loc))
+	}
+
+	return set, nil
+}
+
+// parseCron parses a cron with five fields, or six fields if it starts with seconds.
+func parseCron(spec string) (cron.Schedule, error) {
+	if len(strings.Fields(spec)) == 6 {
+		return secondsParser.Parse(spec)
+	}
+	return parser.Parse(spec)
+}
+
+// getSkippedWallClockFire returns when clocks were set forward between t and end, if sched would have fired at a wall
+// clock time they skipped, or zero otherwise. The cron library skips those fires, since the times don't exist.
+func getSkippedWallClockFire(sched cron.Schedule, t, end time.Time) time.Time {
+	for change := getClockChange(t, end); !change.IsZero(); change = getClockChange(change, end) {
+		_, before := change.Add(-time.Nanosecond).Zone()
+		_, after := change.Zone()
+		if after <= before {
+			continue
+		}
+
+		// the skipped wall clock times are the instants from the change to the end of the gap, read in the offset before.
+		gap := time.Duration(after-before) * time.Second
+		if fire := sched.Next(change.Add(-time.Nanosecond).In(time.FixedZone("", before))); fire.Before(change.Add(gap)) {
+			return change.In(t.Location())
+		}
+	}
+	return time.Time{}
+}
+
+// getClockChange returns the first instant after t and at or before end when the offset of t's location changes, or zero.
+func getClockChange(t, end time.Time) time.Time {
+	const step = 24 * time.Hour // offsets never change twice in a day.
+	for from := t; from.Before(end); from = from.Add(step) {
+		to := from.Add(step)
+		if to.After(end) {
+			to = end
+		}
+
+		_, offset := from.Zone()
+		if _, toOffset := to.Zone(); toOffset == offset {
+			continue
+		}
+
+		// the offset changes in (from, to].
+		for to.Sub(from) > time.Nanosecond {
+			mid := from.Add(to.Sub(from) / 2)
+			if _, midOffset := mid.Zone(); midOffset == offset {
+				from = mid
+			} else {
+				to = mid
+			}
+		}
+		return to
+	}
+	return time.Time{}
+}
+
+// isRepeatedWallClock returns whether t's wall clock time already happened earlier, because clocks were set back.
+func isRepeatedWallClock(t time.Time) bool {
+	_, offset := t.Zone()
+	_, offsetBefore := t.Add(-3 * time.Hour).Zone() // offset changes are never more than a few hours.
+	if offsetBefore <= offset {
+		return false
+	}
+
+	// t is repeated if the instant with the same wall clock time before the change is still before the change.
+	_, earlierOffset := t.Add(-time.Duration(offsetBefore-offset) * time.Second).Zone()
+	return earlierOffset == offsetBefore
+}
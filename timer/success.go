@@ -0,0 +1,129 @@
+package timer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nivista/steady/.gen/protos/common"
+)
+
+type (
+	// successCriteria is a validated common.SuccessCriteria.
+	successCriteria struct {
+		statusCodes  map[int]bool // any 2xx status code if empty.
+		bodyContains []byte
+		jsonPaths    []jsonPathAssertion
+	}
+
+	jsonPathAssertion struct {
+		raw    string
+		path   []string
+		equals interface{} // only the existence of path is checked if nil.
+	}
+)
+
+func newSuccessCriteria(pb *common.SuccessCriteria) (*successCriteria, error) {
+	criteria := successCriteria{
+		statusCodes:  make(map[int]bool, len(pb.GetStatusCodes())),
+		bodyContains: []byte(pb.GetBodyContains()),
+	}
+
+	for _, code := range pb.GetStatusCodes() {
+		if code <= 0 {
+			return nil, fmt.Errorf("invalid status code %v", code)
+		}
+		criteria.statusCodes[int(code)] = true
+	}
+
+	for _, assertion := range pb.GetJsonPathAssertions() {
+		path := strings.TrimPrefix(assertion.Path, "$.")
+		if path == "" {
+			return nil, errors.New("empty json path")
+		}
+
+		jsonPath := jsonPathAssertion{
+			raw:  assertion.Path,
+			path: strings.Split(path, "."),
+		}
+
+		if assertion.Equals != "" {
+			if err := json.Unmarshal([]byte(assertion.Equals), &jsonPath.equals); err != nil {
+				return nil, fmt.Errorf("json path %v: invalid expected value: %w", assertion.Path, err)
+			}
+		}
+
+		criteria.jsonPaths = append(criteria.jsonPaths, jsonPath)
+	}
+
+	return &criteria, nil
+}
+
+// needsBody returns whether the response body is needed to check the criteria.
+func (c *successCriteria) needsBody() bool {
+	return len(c.bodyContains) > 0 || len(c.jsonPaths) > 0
+}
+
+// check returns a description of the first criterion the response fails, or the empty string if it succeeds.
+func (c *successCriteria) check(statusCode int, body []byte) string {
+	if len(c.statusCodes) == 0 {
+		if statusCode < 200 || statusCode > 299 {
+			return fmt.Sprintf("status code %v isn't 2xx", statusCode)
+		}
+	} else if !c.statusCodes[statusCode] {
+		return fmt.Sprintf("status code %v isn't a success status code", statusCode)
+	}
+
+	if len(c.bodyContains) > 0 && !bytes.Contains(body, c.bodyContains) {
+		return fmt.Sprintf("body doesn't contain %q", c.bodyContains)
+	}
+
+	if len(c.jsonPaths) == 0 {
+		return ""
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "body isn't valid JSON"
+	}
+
+	for _, assertion := range c.jsonPaths {
+		value, ok := lookupJSONPath(doc, assertion.path)
+		if !ok {
+			return fmt.Sprintf("json path %v doesn't exist", assertion.raw)
+		}
+
+		if assertion.equals != nil && !reflect.DeepEqual(value, assertion.equals) {
+			return fmt.Sprintf("json path %v doesn't have the expected value", assertion.raw)
+		}
+	}
+
+	return ""
+}
+
+func lookupJSONPath(doc interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if doc, ok = node[key]; !ok {
+				return nil, false
+			}
+
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			doc = node[idx]
+
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
@@ -0,0 +1,78 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/nivista/steady/.gen/protos/common"
+)
+
+var successTestCases = []struct {
+	criteria   *common.SuccessCriteria
+	statusCode int
+	body       string
+	success    bool
+}{
+	// Defaults to any 2xx status code.
+	{nil, 204, "", true},
+	{nil, 301, "", false},
+	{nil, 503, "", false},
+
+	// Explicit status codes.
+	{&common.SuccessCriteria{StatusCodes: []int32{200, 404}}, 404, "", true},
+	{&common.SuccessCriteria{StatusCodes: []int32{200, 404}}, 201, "", false},
+
+	// Body substring.
+	{&common.SuccessCriteria{BodyContains: "ok"}, 200, "status: ok", true},
+	{&common.SuccessCriteria{BodyContains: "ok"}, 200, "status: error", false},
+
+	// JSON paths.
+	{
+		&common.SuccessCriteria{JsonPathAssertions: []*common.JSONPathAssertion{{Path: "data.items.1.status", Equals: `"done"`}}},
+		200, `{"data": {"items": [{"status": "pending"}, {"status": "done"}]}}`, true,
+	},
+	{
+		&common.SuccessCriteria{JsonPathAssertions: []*common.JSONPathAssertion{{Path: "$.data.count", Equals: `2`}}},
+		200, `{"data": {"count": 3}}`, false,
+	},
+	{
+		&common.SuccessCriteria{JsonPathAssertions: []*common.JSONPathAssertion{{Path: "data.id"}}},
+		200, `{"data": {"id": "abc"}}`, true,
+	},
+	{
+		&common.SuccessCriteria{JsonPathAssertions: []*common.JSONPathAssertion{{Path: "data.id"}}},
+		200, `{"data": {}}`, false,
+	},
+	{
+		&common.SuccessCriteria{JsonPathAssertions: []*common.JSONPathAssertion{{Path: "data"}}},
+		200, `not json`, false,
+	},
+}
+
+func TestSuccessCriteria(t *testing.T) {
+	for idx, tc := range successTestCases {
+		criteria, err := newSuccessCriteria(tc.criteria)
+		if err != nil {
+			t.Errorf("case: %v. newSuccessCriteria: %v", idx, err)
+			continue
+		}
+
+		failure := criteria.check(tc.statusCode, []byte(tc.body))
+		if (failure == "") != tc.success {
+			t.Errorf("case: %v. expected success %v, got failure %q", idx, tc.success, failure)
+		}
+	}
+}
+
+func TestInvalidSuccessCriteria(t *testing.T) {
+	criteria := []*common.SuccessCriteria{
+		{StatusCodes: []int32{0}},
+		{JsonPathAssertions: []*common.JSONPathAssertion{{Path: ""}}},
+		{JsonPathAssertions: []*common.JSONPathAssertion{{Path: "data", Equals: "not json"}}},
+	}
+
+	for idx, c := range criteria {
+		if _, err := newSuccessCriteria(c); err == nil {
+			t.Errorf("case: %v. expected error", idx)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package timer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templateVariable matches a variable in a template, like {{scheduled_time}}.
+var templateVariable = regexp.MustCompile(`{{\s*([a-z_]+)\s*}}`)
+
+// templateVariables are the values a template can refer to, by name.
+// Templates can only substitute these values, so rendering can't fail or run arbitrary code.
+var templateVariables = map[string]func(e execution) string{
+	"scheduled_time":      func(e execution) string { return e.scheduledTime.UTC().Format(time.RFC3339) },
+	"scheduled_time_unix": func(e execution) string { return strconv.FormatInt(e.scheduledTime.Unix(), 10) },
+	"fire_time":           func(e execution) string { return e.fireTime.UTC().Format(time.RFC3339Nano) },
+	"fire_time_unix":      func(e execution) string { return strconv.FormatInt(e.fireTime.Unix(), 10) },
+	"execution_number":    func(e execution) string { return strconv.FormatInt(int64(e.number), 10) },
+	"execution_id":        func(e execution) string { return e.id },
+	"timer_uuid":          func(e execution) string { return e.timerUUID },
+	"domain":              func(e execution) string { return e.domain },
+}
+
+// validateTemplate returns an error if s refers to an unknown variable.
+func validateTemplate(s string) error {
+	for _, match := range templateVariable.FindAllStringSubmatch(s, -1) {
+		if _, ok := templateVariables[match[1]]; !ok {
+			return fmt.Errorf("unknown template variable %v", match[1])
+		}
+	}
+	return nil
+}
+
+// renderTemplate substitutes the variables in s with their values for e, passed through escape if it isn't nil.
+func renderTemplate(s string, e execution, escape func(string) string) string {
+	return templateVariable.ReplaceAllStringFunc(s, func(match string) string {
+		name := templateVariable.FindStringSubmatch(match)[1]
+		value := templateVariables[name](e)
+		if escape != nil {
+			value = escape(value)
+		}
+		return value
+	})
+}
+
+// renderURLTemplate renders a url template, escaping each value for the component of the url it's substituted in.
+// The template is split before it's rendered, so values containing '?' or '#' don't change the components.
+func renderURLTemplate(s string, e execution) string {
+	var query, fragment string
+	if i := strings.Index(s, "#"); i >= 0 {
+		s, fragment = s[:i], s[i:]
+	}
+	if i := strings.Index(s, "?"); i >= 0 {
+		s, query = s[:i], s[i:]
+	}
+	return renderTemplate(s, e, url.PathEscape) + renderTemplate(query, e, url.QueryEscape) + renderTemplate(fragment, e, url.PathEscape)
+}
@@ -0,0 +1,82 @@
+package timer
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	e := execution{
+		id:            "uuid-3",
+		timerUUID:     "uuid",
+		domain:        "domain",
+		number:        3,
+		scheduledTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		fireTime:      time.Date(2020, 1, 1, 0, 0, 0, 5e8, time.UTC),
+	}
+
+	var tests = []struct {
+		name     string
+		template string
+		escape   func(string) string
+		expected string
+	}{
+		{"no variables", "plain {text}", nil, "plain {text}"},
+		{"every variable",
+			"{{scheduled_time}} {{scheduled_time_unix}} {{fire_time}} {{fire_time_unix}} {{execution_number}} {{execution_id}} {{timer_uuid}} {{domain}}",
+			nil,
+			"2020-01-01T00:00:00Z 1577836800 2020-01-01T00:00:00.5Z 1577836800 3 uuid-3 uuid domain"},
+		{"whitespace", `{"n": {{ execution_number }}}`, nil, `{"n": 3}`},
+		{"escaped", "/?at={{scheduled_time}}", url.QueryEscape, "/?at=2020-01-01T00%3A00%3A00Z"},
+	}
+
+	for _, test := range tests {
+		if res := renderTemplate(test.template, e, test.escape); res != test.expected {
+			t.Errorf("case: %v. got %q, expected %q", test.name, res, test.expected)
+		}
+	}
+}
+
+func TestRenderURLTemplate(t *testing.T) {
+	e := execution{
+		domain:        "a b/c?d&e#f",
+		scheduledTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var tests = []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"path", "http://example.com/{{domain}}/x", "http://example.com/a%20b%2Fc%3Fd&e%23f/x"},
+		{"query", "http://example.com/?d={{domain}}&at={{scheduled_time}}", "http://example.com/?d=a+b%2Fc%3Fd%26e%23f&at=2020-01-01T00%3A00%3A00Z"},
+		{"fragment", "http://example.com/#{{domain}}", "http://example.com/#a%20b%2Fc%3Fd&e%23f"},
+		{"every component", "http://example.com/{{domain}}?d={{domain}}#{{domain}}",
+			"http://example.com/a%20b%2Fc%3Fd&e%23f?d=a+b%2Fc%3Fd%26e%23f#a%20b%2Fc%3Fd&e%23f"},
+	}
+
+	for _, test := range tests {
+		if res := renderURLTemplate(test.template, e); res != test.expected {
+			t.Errorf("case: %v. got %q, expected %q", test.name, res, test.expected)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	var tests = []struct {
+		template string
+		valid    bool
+	}{
+		{"", true},
+		{"{{domain}}/{{timer_uuid}}", true},
+		{"{{ unknown }}", false},
+		{"{{Domain}}", true}, // doesn't match the variable syntax, so it's left as is.
+	}
+
+	for _, test := range tests {
+		if err := validateTemplate(test.template); (err == nil) != test.valid {
+			t.Errorf("case: %v. got error %v, expected valid: %v", test.template, err, test.valid)
+		}
+	}
+}
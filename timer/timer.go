@@ -1,14 +1,18 @@
 package timer
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/uuid"
 	"github.com/jonboulle/clockwork"
 	"go.uber.org/atomic"
 
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -27,6 +31,10 @@ type (
 		schedule
 		progress
 
+		retry *retryPolicy
+
+		domain, timerUUID string
+
 		recordExecution   func(*messaging.Execute)
 		recordTermination func()
 
@@ -35,47 +43,145 @@ type (
 		active     *atomic.Bool
 		terminated bool
 		stop       chan struct{}
+		done       chan struct{} // closed when the timer stops running, including when it finishes.
+
+		// ctx is cancelled by Stop to abort in flight executions.
+		ctx    context.Context
+		cancel context.CancelFunc
 	}
 
 	// making my own type here rather than using protobuf, this can be safely copied.
 	progress struct {
 		completedExecutions int32
 		lastExecution       *time.Time
+		lastScheduled       *time.Time // when the last execution was scheduled for.
 	}
 )
 
 // IsValid validates a create timer message.
 func IsValid(pb *messaging.Create) error {
-	_, err := New(pb, nil, nil, nil)
+	_, err := New(pb, nil, nil, nil, nil)
 	return err
 }
 
 // New creates a Timer from the given create message and handlers.
-func New(create *messaging.Create, recordExecution func(*messaging.Execute), recordTermination func(), clock clockwork.Clock) (Timer, error) {
-	return NewWithProgress(create, nil, recordExecution, recordTermination, clock)
+// The producer is used by kafka publish tasks.
+func New(create *messaging.Create, recordExecution func(*messaging.Execute), recordTermination func(), clock clockwork.Clock, producer Producer) (Timer, error) {
+	return NewWithProgress(create, nil, recordExecution, recordTermination, clock, producer)
 }
 
 // NewWithProgress creates a new timer with the given create message, progress, and handlers.
-func NewWithProgress(create *messaging.Create, prog *messaging.Progress, recordExecution func(*messaging.Execute), recordTermination func(), clock clockwork.Clock) (Timer, error) {
-	exec, err := newExecute(create.Task)
+// The producer is used by kafka publish tasks.
+func NewWithProgress(create *messaging.Create, prog *messaging.Progress, recordExecution func(*messaging.Execute), recordTermination func(), clock clockwork.Clock, producer Producer) (Timer, error) {
+	t, err := newTimer(create, prog, recordExecution, recordTermination, clock, producer)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Trigger executes the task of the timer once now, outside of its schedule, and returns the manual execution.
+// Manual executions don't affect the timers progress.
+func Trigger(create *messaging.Create, trigger *messaging.Trigger, clock clockwork.Clock, producer Producer) (*messaging.Execute, error) {
+	t, err := newTimer(create, nil, nil, nil, clock, producer)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	e := execution{
+		id:            fmt.Sprintf("%v-trigger-%v", t.timerUUID, trigger.TriggerId),
+		timerUUID:     t.timerUUID,
+		domain:        t.domain,
+		scheduledTime: now,
+		fireTime:      now,
+	}
+	res := t.executeWithRetries(e)
+
+	return &messaging.Execute{
+		Result:    res.result,
+		Outcome:   getOutcome(res),
+		Manual:    true,
+		TriggerId: trigger.TriggerId,
+		Duration:  durationpb.New(clock.Since(now)),
+	}, nil
+}
+
+// CurrentProgress returns the progress a timer continues from.
+// A timer reset after prog was made starts over from its update time, so fires before the reset aren't missed fires.
+func CurrentProgress(create *messaging.Create, prog *messaging.Progress) *messaging.Progress {
+	if !create.ResetProgress || prog.GetLastExecution().AsTime().After(create.Meta.GetUpdateTime().AsTime()) {
+		return prog
+	}
+
+	return &messaging.Progress{
+		LastScheduled: timestamppb.New(create.Meta.GetUpdateTime().AsTime().Add(rollback)),
+	}
+}
+
+// maxSkippedFires is how many fires excluded by calendars NextFire looks past.
+const maxSkippedFires = 1000
+
+// NextFire returns when a timer with the given progress fires next after now, it's zero if it won't fire again.
+// Fires excluded by calendars aren't returned.
+func NextFire(create *messaging.Create, prog *messaging.Progress, now time.Time) (time.Time, error) {
+	sched, err := newSchedule(create.Schedule, create.Calendars, create.Meta.GetTimerUuid())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	p := progressFromProto(prog)
+	for i := 0; i < maxSkippedFires; i++ {
+		f := sched(p, now)
+		if f == nil {
+			return time.Time{}, nil
+		}
+
+		if f.skipped == "" {
+			return f.at, nil
+		}
+		p.lastScheduled = &f.slot
+	}
+	return time.Time{}, nil
+}
+
+func newTimer(create *messaging.Create, prog *messaging.Progress, recordExecution func(*messaging.Execute), recordTermination func(), clock clockwork.Clock, producer Producer) (*timer, error) {
+	if err := validateLabels(create.Labels); err != nil {
+		return nil, errors.New("invalid labels: " + err.Error())
+	}
+
+	exec, err := newExecute(create.Task, create.Meta.GetDomain(), producer)
 	if err != nil {
 		return nil, errors.New("invalid task: " + err.Error())
 	}
 
-	sched, err := newSchedule(create.Schedule)
+	retry, err := newRetryPolicy(create.Task.GetRetryPolicy())
+	if err != nil {
+		return nil, errors.New("invalid retry policy: " + err.Error())
+	}
+
+	sched, err := newSchedule(create.Schedule, create.Calendars, create.Meta.GetTimerUuid())
 	if err != nil {
 		return nil, errors.New("invalid schedule: " + err.Error())
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &timer{
 		execute:           exec,
 		schedule:          sched,
+		retry:             retry,
+		domain:            create.Meta.GetDomain(),
+		timerUUID:         create.Meta.GetTimerUuid(),
 		progress:          progressFromProto(prog),
 		clock:             clock,
 		recordExecution:   recordExecution,
 		recordTermination: recordTermination,
 		active:            atomic.NewBool(false),
 		stop:              make(chan struct{}),
+		done:              make(chan struct{}),
+		ctx:               ctx,
+		cancel:            cancel,
 	}, nil
 }
 
@@ -89,6 +195,7 @@ func (t *timer) Start() {
 	}
 
 	go func() {
+		defer close(t.done)
 
 		for {
 			currFire := t.schedule(t.progress, t.clock.Now())
@@ -98,18 +205,39 @@ func (t *timer) Start() {
 				return
 			}
 
-			deadline := currFire.Sub(t.clock.Now())
+			deadline := currFire.at.Sub(t.clock.Now())
+			if deadline < 0 { // missed fire, it happens right away.
+				deadline = 0
+			}
 
 			select {
 			case now := <-t.clock.After(deadline):
-				res := t.execute()
+				if currFire.skipped != "" { // skipped fires don't count as executions.
+					t.progress.lastScheduled = &currFire.slot
+					t.recordExecution(&messaging.Execute{
+						Progress: progressToProto(t.progress),
+						Result:   getSkippedJSON(currFire.skipped),
+						Outcome:  messaging.Outcome_OUTCOME_SKIPPED,
+					})
+					continue
+				}
+
+				res := t.executeWithRetries(t.newExecution(currFire.slot, now))
+
+				if t.ctx.Err() != nil { // stopped mid execution, the result is dropped.
+					<-t.stop
+					return
+				}
 
 				t.progress.completedExecutions++
 				t.progress.lastExecution = &now
+				t.progress.lastScheduled = &currFire.slot
 
 				t.recordExecution(&messaging.Execute{
 					Progress: progressToProto(t.progress),
-					Result:   res,
+					Result:   res.result,
+					Outcome:  getOutcome(res),
+					Duration: durationpb.New(t.clock.Since(now)),
 				})
 
 			case <-t.stop:
@@ -122,21 +250,56 @@ func (t *timer) Start() {
 
 func (t *timer) Stop() {
 	t.terminated = true
+	t.cancel()
 	if !t.active.CAS(true, false) {
 		return
 	}
 
-	t.stop <- struct{}{} // block until timer stops
+	select { // block until timer stops
+	case t.stop <- struct{}{}:
+	case <-t.done: // the timer already finished.
+	}
+}
+
+// newExecution describes the next fire of the timer, for the slot scheduledTime and fired at fireTime.
+func (t *timer) newExecution(scheduledTime, fireTime time.Time) execution {
+	e := execution{
+		timerUUID:     t.timerUUID,
+		domain:        t.domain,
+		number:        t.progress.completedExecutions + 1,
+		scheduledTime: scheduledTime,
+		fireTime:      fireTime,
+	}
+
+	// the same fire of a timer has the same id, even if it's repeated by another node.
+	if t.timerUUID != "" {
+		e.id = fmt.Sprintf("%v-%v", t.timerUUID, e.number)
+	} else {
+		e.id = uuid.New().String()
+	}
+
+	return e
+}
+
+func getOutcome(a attempt) messaging.Outcome {
+	if a.success {
+		return messaging.Outcome_OUTCOME_SUCCESS
+	}
+	return messaging.Outcome_OUTCOME_FAILURE
 }
 
 func progressToProto(p progress) *messaging.Progress {
-	var last *timestamp.Timestamp
+	var last, lastScheduled *timestamp.Timestamp
 	if p.lastExecution != nil {
 		last = timestamppb.New(*p.lastExecution)
 	}
+	if p.lastScheduled != nil {
+		lastScheduled = timestamppb.New(*p.lastScheduled)
+	}
 	return &messaging.Progress{
 		CompletedExecutions: p.completedExecutions,
 		LastExecution:       last,
+		LastScheduled:       lastScheduled,
 	}
 }
 
@@ -148,8 +311,13 @@ func progressFromProto(pb *messaging.Progress) progress {
 	if pb.LastExecution != nil {
 		last = pb.LastExecution.AsTime()
 	}
-	return progress{
+	prog := progress{
 		completedExecutions: pb.CompletedExecutions,
 		lastExecution:       &last,
 	}
+	if pb.LastScheduled != nil {
+		lastScheduled := pb.LastScheduled.AsTime()
+		prog.lastScheduled = &lastScheduled
+	}
+	return prog
 }
@@ -2,6 +2,10 @@ package timer
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sort"
 	"testing"
 	"time"
@@ -10,6 +14,8 @@ import (
 	"github.com/nivista/steady/.gen/protos/common"
 	"github.com/nivista/steady/internal/.gen/protos/messaging"
 	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -24,7 +30,7 @@ var testCases = []struct {
 		timer: newTimerOptimistic(
 			&common.Schedule{
 				// intended execution: fires once 1 second past epoch.
-				Cron:          "@every 1s",
+				Spec:          &common.Schedule_Cron{Cron: "@every 1s"},
 				StartTime:     timestamppb.New(time.Unix(1, 0)),
 				MaxExecutions: 1,
 			},
@@ -46,7 +52,7 @@ var testCases = []struct {
 		timer: newTimerOptimistic(
 			&common.Schedule{
 				// intended execution: fires 60 and 120 seconds past epoch.
-				Cron:          "@every 1m",
+				Spec:          &common.Schedule_Cron{Cron: "@every 1m"},
 				StartTime:     timestamppb.New(time.Unix(60, 0)),
 				MaxExecutions: 2,
 			},
@@ -67,12 +73,220 @@ var testCases = []struct {
 		expectFinish: true,
 	},
 
+	// Seconds field, sub minute fires.
+	{
+		timer: newTimerOptimistic(
+			&common.Schedule{
+				// intended execution: fires 10 and 20 seconds past epoch.
+				Spec:          &common.Schedule_Cron{Cron: "*/10 * * * * *"},
+				StartTime:     timestamppb.New(time.Unix(1, 0)),
+				MaxExecutions: 2,
+			},
+			progress{}), // no progress
+		startTime: time.Unix(0, 0),
+		expectedResults: map[time.Time]*messaging.Execute{
+			time.Unix(10, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 1,
+					LastExecution:       timestamppb.New(time.Unix(10, 0)),
+				},
+			},
+			time.Unix(20, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 2,
+					LastExecution:       timestamppb.New(time.Unix(20, 0)),
+				},
+			},
+		},
+		expectFinish: true,
+	},
+
+	// Time zone aware fires across clocks being set forward.
+	{
+		timer: newTimerOptimistic(
+			&common.Schedule{
+				// intended execution: 9am New York time, which is 14:00 UTC before March 8th 2020 and 13:00 UTC after.
+				Spec:          &common.Schedule_Cron{Cron: "0 9 * * *"},
+				StartTime:     timestamppb.New(time.Date(2020, 3, 7, 0, 0, 0, 0, time.UTC)),
+				MaxExecutions: 3,
+				Timezone:      "America/New_York",
+			},
+			progress{}),
+		startTime: time.Date(2020, 3, 7, 0, 0, 0, 0, time.UTC),
+		expectedResults: map[time.Time]*messaging.Execute{
+			time.Date(2020, 3, 7, 14, 0, 0, 0, time.UTC): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 1,
+					LastExecution:       timestamppb.New(time.Date(2020, 3, 7, 14, 0, 0, 0, time.UTC)),
+				},
+			},
+			time.Date(2020, 3, 8, 13, 0, 0, 0, time.UTC): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 2,
+					LastExecution:       timestamppb.New(time.Date(2020, 3, 8, 13, 0, 0, 0, time.UTC)),
+				},
+			},
+			time.Date(2020, 3, 9, 13, 0, 0, 0, time.UTC): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 3,
+					LastExecution:       timestamppb.New(time.Date(2020, 3, 9, 13, 0, 0, 0, time.UTC)),
+				},
+			},
+		},
+		expectFinish: true,
+	},
+
+	// Every missed fire happens, late ones right away.
+	{
+		timer: newTimerOptimistic(
+			&common.Schedule{
+				// intended execution: fires 60, 120 and 180 seconds past epoch.
+				Spec:          &common.Schedule_Cron{Cron: "@every 1m"},
+				StartTime:     timestamppb.New(time.Unix(60, 0)),
+				MaxExecutions: 3,
+				MisfirePolicy: common.MisfirePolicy_MISFIRE_POLICY_FIRE_ALL,
+			},
+			progress{
+				// correctly did its first fire
+				lastExecution:       unixTimePointer(60),
+				lastScheduled:       unixTimePointer(60),
+				completedExecutions: 1,
+			}),
+		startTime: time.Unix(150, 0), // node doesn't get the timer until 150 seconds past epoch
+		expectedResults: map[time.Time]*messaging.Execute{
+			time.Unix(150, 0): { // late fire for 120 seconds past epoch
+				Progress: &messaging.Progress{
+					CompletedExecutions: 2,
+					LastExecution:       timestamppb.New(time.Unix(150, 0)),
+				},
+			},
+			time.Unix(180, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 3,
+					LastExecution:       timestamppb.New(time.Unix(180, 0)),
+				},
+			},
+		},
+		expectFinish: true,
+	},
+
+	// One shot fire, without a start time.
+	{
+		timer: newTimerOptimistic(
+			&common.Schedule{
+				// intended execution: fires once 30 seconds past epoch.
+				Spec: &common.Schedule_At{At: timestamppb.New(time.Unix(30, 0))},
+			},
+			progress{}), // no progress
+		startTime: time.Unix(0, 0),
+		expectedResults: map[time.Time]*messaging.Execute{
+			time.Unix(30, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 1,
+					LastExecution:       timestamppb.New(time.Unix(30, 0)),
+				},
+			},
+		},
+		expectFinish: true,
+	},
+
+	// Fixed interval anchored at the start time.
+	{
+		timer: newTimerOptimistic(
+			&common.Schedule{
+				// intended execution: fires 5, 95 and 185 seconds past epoch.
+				Spec:          &common.Schedule_Interval{Interval: durationpb.New(90 * time.Second)},
+				StartTime:     timestamppb.New(time.Unix(5, 0)),
+				MaxExecutions: 3,
+			},
+			progress{}), // no progress
+		startTime: time.Unix(0, 0),
+		expectedResults: map[time.Time]*messaging.Execute{
+			time.Unix(5, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 1,
+					LastExecution:       timestamppb.New(time.Unix(5, 0)),
+				},
+			},
+			time.Unix(95, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 2,
+					LastExecution:       timestamppb.New(time.Unix(95, 0)),
+				},
+			},
+			time.Unix(185, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 3,
+					LastExecution:       timestamppb.New(time.Unix(185, 0)),
+				},
+			},
+		},
+		expectFinish: true,
+	},
+
+	// Missed one shot is skipped, and the timer terminates.
+	{
+		timer: newTimerOptimistic(
+			&common.Schedule{
+				Spec:          &common.Schedule_At{At: timestamppb.New(time.Unix(30, 0))},
+				MisfirePolicy: common.MisfirePolicy_MISFIRE_POLICY_SKIP,
+			},
+			progress{}), // no progress
+		startTime:       time.Unix(60, 0), // node doesn't get the timer until after the fire
+		expectedResults: map[time.Time]*messaging.Execute{},
+		expectFinish:    true,
+	},
+
+	// Fire excluded by a calendar is skipped, and doesn't count as an execution.
+	{
+		timer: newTimerOptimisticWithCalendars(
+			&common.Schedule{
+				// intended execution: fires 60 and 180 seconds past epoch, skips 120 seconds past epoch.
+				Spec:          &common.Schedule_Interval{Interval: durationpb.New(time.Minute)},
+				StartTime:     timestamppb.New(time.Unix(60, 0)),
+				MaxExecutions: 2,
+				Calendars:     []string{"maintenance"},
+			},
+			[]*common.Calendar{{
+				Name: "maintenance",
+				Ranges: []*common.TimeRange{{
+					Start: timestamppb.New(time.Unix(100, 0)),
+					End:   timestamppb.New(time.Unix(140, 0)),
+				}},
+			}},
+			progress{}), // no progress
+		startTime: time.Unix(0, 0),
+		expectedResults: map[time.Time]*messaging.Execute{
+			time.Unix(60, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 1,
+					LastExecution:       timestamppb.New(time.Unix(60, 0)),
+				},
+			},
+			time.Unix(120, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 1,
+					LastExecution:       timestamppb.New(time.Unix(60, 0)),
+				},
+				Result:  []byte(`{"Skipped":true,"Calendar":"maintenance"}`),
+				Outcome: messaging.Outcome_OUTCOME_SKIPPED,
+			},
+			time.Unix(180, 0): {
+				Progress: &messaging.Progress{
+					CompletedExecutions: 2,
+					LastExecution:       timestamppb.New(time.Unix(180, 0)),
+				},
+			},
+		},
+		expectFinish: true,
+	},
+
 	// Zero fires, external termination.
 	{
 		timer: newTimerOptimistic(
 			&common.Schedule{
 				// intended execution: fires 60 and 120 seconds past epoch.
-				Cron:          "@every 1m",
+				Spec:          &common.Schedule_Cron{Cron: "@every 1m"},
 				StartTime:     timestamppb.New(time.Unix(60, 0)),
 				MaxExecutions: 2,
 			},
@@ -112,7 +326,10 @@ Outer:
 		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
 
 		for _, exec := range times {
-			fc.Advance(exec.Sub(fc.Now())) // wait until next expected result
+			if wait := exec.Sub(fc.Now()); wait > 0 {
+				fc.BlockUntil(1) // make sure the timer is waiting before advancing the clock
+				fc.Advance(wait) // wait until next expected result
+			}
 
 			select {
 			case execMsg := <-executions:
@@ -122,7 +339,7 @@ Outer:
 					continue Outer
 				}
 
-				if execMsg.Progress.LastExecution.Nanos != expected.Progress.LastExecution.Nanos {
+				if !execMsg.Progress.LastExecution.AsTime().Equal(expected.Progress.LastExecution.AsTime()) {
 					t.Errorf("case: %v. time: %v. Unequal 'Progress.LastExecution'", idx, exec)
 					continue Outer
 				}
@@ -132,6 +349,11 @@ Outer:
 					continue Outer
 				}
 
+				if expected.Outcome != messaging.Outcome_OUTCOME_UNKNOWN && execMsg.Outcome != expected.Outcome {
+					t.Errorf("case: %v. time: %v. Unequal 'Outcome'", idx, exec)
+					continue Outer
+				}
+
 			case <-time.After(10 * time.Millisecond): // give time for go scheduler to give control to other goroutine
 				t.Errorf("case: %v. time: %v. Expected execution.", idx, exec)
 				continue Outer
@@ -144,35 +366,538 @@ Outer:
 			case <-time.After(10 * time.Millisecond):
 				t.Errorf("case: %v. Expected finish.", idx)
 			}
+		}
+
+		// stopping doesn't block, whether or not the timer finished.
+		stopped := make(chan struct{})
+		go func() {
+			cfg.timer.Stop()
+			stopped <- struct{}{}
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(10 * time.Millisecond):
+			t.Errorf("case: %v. Stop is blocking for too long.", idx)
+		}
+	}
+}
+
+func TestInvalidSchedule(t *testing.T) {
+	var tests = []struct {
+		name string
+		pb   *common.Schedule
+	}{
+		{"no spec", &common.Schedule{StartTime: timestamppb.New(time.Unix(0, 0))}},
+		{"cron without start time", &common.Schedule{Spec: &common.Schedule_Cron{Cron: "* * * * *"}}},
+		{"interval without start time", &common.Schedule{Spec: &common.Schedule_Interval{Interval: durationpb.New(time.Second)}}},
+		{"zero interval", &common.Schedule{
+			Spec:      &common.Schedule_Interval{Interval: durationpb.New(0)},
+			StartTime: timestamppb.New(time.Unix(0, 0)),
+		}},
+		{"invalid at", &common.Schedule{Spec: &common.Schedule_At{At: &timestamppb.Timestamp{Nanos: -1}}}},
+		{"timezone without cron", &common.Schedule{
+			Spec:     &common.Schedule_At{At: timestamppb.New(time.Unix(0, 0))},
+			Timezone: "America/New_York",
+		}},
+		{"negative max executions", &common.Schedule{
+			Spec:          &common.Schedule_At{At: timestamppb.New(time.Unix(0, 0))},
+			MaxExecutions: -1,
+		}},
+	}
+
+	for _, test := range tests {
+		if _, err := newSchedule(test.pb, nil, ""); err == nil {
+			t.Errorf("case: %v. expected error", test.name)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	var tests = []struct {
+		spec  string
+		valid bool
+	}{
+		{"*/5 * * * *", true},
+		{"*/10 * * * * *", true},
+		{"30 0 9 * * MON-FRI", true},
+		{"@every 500ms", true},
+		{"60 * * * * *", false},
+		{"* * * * * * *", false},
+		{"* * * *", false},
+	}
+
+	for _, test := range tests {
+		if _, err := parseCron(test.spec); (err == nil) != test.valid {
+			t.Errorf("case: %v. got error %v, expected valid: %v", test.spec, err, test.valid)
+		}
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	var tests = []struct {
+		name     string
+		cron     string
+		start    time.Time
+		expected []time.Time
+	}{
+		{
+			name:  "clocks set forward",
+			cron:  "0 9 * * *",
+			start: time.Date(2020, 3, 7, 0, 0, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2020, 3, 7, 14, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 8, 13, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 9, 13, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "clocks set forward, the skipped wall clock time fires when they're set forward",
+			cron:  "30 2 * * *",
+			start: time.Date(2020, 3, 7, 12, 0, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2020, 3, 8, 7, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 9, 6, 30, 0, 0, time.UTC),
+				time.Date(2020, 3, 10, 6, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "clocks set forward, several skipped wall clock times fire once",
+			cron:  "*/30 * * * *",
+			start: time.Date(2020, 3, 8, 6, 0, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2020, 3, 8, 6, 30, 0, 0, time.UTC),
+				time.Date(2020, 3, 8, 7, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 8, 7, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "clocks set back, the repeated wall clock time fires once",
+			cron:  "30 1 * * *",
+			start: time.Date(2020, 10, 31, 0, 0, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2020, 10, 31, 5, 30, 0, 0, time.UTC),
+				time.Date(2020, 11, 1, 5, 30, 0, 0, time.UTC),
+				time.Date(2020, 11, 2, 6, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "intervals ignore the time zone",
+			cron:  "@every 1h",
+			start: time.Date(2020, 11, 1, 5, 0, 0, 0, time.UTC),
+			expected: []time.Time{
+				time.Date(2020, 11, 1, 6, 0, 0, 0, time.UTC),
+				time.Date(2020, 11, 1, 7, 0, 0, 0, time.UTC),
+				time.Date(2020, 11, 1, 8, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		sched, err := newSchedule(&common.Schedule{
+			Spec:      &common.Schedule_Cron{Cron: test.cron},
+			StartTime: timestamppb.New(test.start),
+			Timezone:  "America/New_York",
+		}, nil, "")
+		if err != nil {
+			t.Fatalf("case: %v. newSchedule: %v", test.name, err)
+		}
+
+		prog := progress{lastExecution: &test.start}
+		for _, expected := range test.expected {
+			next := sched(prog, test.start)
+			if next == nil || !next.at.Equal(expected) {
+				t.Errorf("case: %v. got fire %v, expected %v", test.name, next, expected)
+				break
+			}
+			prog.completedExecutions++
+			prog.lastScheduled = &next.slot
+		}
+	}
+}
+
+func TestMisfirePolicy(t *testing.T) {
+	var tests = []struct {
+		name        string
+		policy      common.MisfirePolicy
+		graceWindow time.Duration
+		expected    []int64 // seconds past epoch.
+	}{
+		{"fire once", common.MisfirePolicy_MISFIRE_POLICY_FIRE_ONCE, 0, []int64{250, 300}},
+		{"fire all", common.MisfirePolicy_MISFIRE_POLICY_FIRE_ALL, 0, []int64{120, 180, 240, 300}},
+		{"skip", common.MisfirePolicy_MISFIRE_POLICY_SKIP, 0, []int64{300, 360}},
+		{"short grace window", common.MisfirePolicy_MISFIRE_POLICY_GRACE_WINDOW, 30 * time.Second, []int64{240, 300}},
+		{"long grace window", common.MisfirePolicy_MISFIRE_POLICY_GRACE_WINDOW, 2 * time.Minute, []int64{180, 240, 300}},
+	}
+
+	// the timer last fired 60 seconds past epoch, and is picked up again 250 seconds past epoch.
+	now := time.Unix(250, 0)
+	for _, test := range tests {
+		pb := &common.Schedule{
+			Spec:          &common.Schedule_Cron{Cron: "* * * * *"},
+			StartTime:     timestamppb.New(time.Unix(60, 0)),
+			MisfirePolicy: test.policy,
+		}
+		if test.graceWindow != 0 {
+			pb.GraceWindow = durationpb.New(test.graceWindow)
+		}
+
+		sched, err := newSchedule(pb, nil, "")
+		if err != nil {
+			t.Fatalf("case: %v. newSchedule: %v", test.name, err)
+		}
+
+		prog := progress{lastScheduled: unixTimePointer(60), completedExecutions: 1}
+		for _, expected := range test.expected {
+			next := sched(prog, now)
+			if next == nil || next.at.Unix() != expected {
+				t.Errorf("case: %v. got fire %v, expected %v", test.name, next, time.Unix(expected, 0))
+				break
+			}
+			prog.completedExecutions++
+			prog.lastScheduled = &next.slot
+		}
+	}
+}
 
-		} else {
+func TestInvalidMisfirePolicy(t *testing.T) {
+	var tests = []struct {
+		name        string
+		policy      common.MisfirePolicy
+		graceWindow *durationpb.Duration
+	}{
+		{"unknown policy", common.MisfirePolicy(10), nil},
+		{"grace window policy without grace window", common.MisfirePolicy_MISFIRE_POLICY_GRACE_WINDOW, nil},
+		{"negative grace window", common.MisfirePolicy_MISFIRE_POLICY_GRACE_WINDOW, durationpb.New(-time.Second)},
+		{"grace window with another policy", common.MisfirePolicy_MISFIRE_POLICY_SKIP, durationpb.New(time.Second)},
+	}
 
-			stopped := make(chan struct{})
-			go func() {
-				cfg.timer.Stop()
-				stopped <- struct{}{}
-			}()
-			select {
-			case <-stopped:
-			case <-time.After(10 * time.Millisecond):
-				t.Errorf("case: %v. Stop is blocking for too long.", idx)
+	for _, test := range tests {
+		_, err := newSchedule(&common.Schedule{
+			Spec:          &common.Schedule_Cron{Cron: "* * * * *"},
+			StartTime:     timestamppb.New(time.Unix(0, 0)),
+			MisfirePolicy: test.policy,
+			GraceWindow:   test.graceWindow,
+		}, nil, "")
+		if err == nil {
+			t.Errorf("case: %v. expected error", test.name)
+		}
+	}
+}
+
+func TestRRule(t *testing.T) {
+	var tests = []struct {
+		name     string
+		rrule    string
+		start    *timestamppb.Timestamp
+		expected []time.Time
+		finite   bool // whether the schedule is expected to terminate after the expected fires.
+	}{
+		{
+			name:  "last business day of the month",
+			rrule: "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			start: timestamppb.New(time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)),
+			expected: []time.Time{
+				time.Date(2020, 1, 31, 9, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 28, 9, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 31, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "every other tuesday, with an exception",
+			rrule: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU\nEXDATE:20200121T090000Z",
+			start: timestamppb.New(time.Date(2020, 1, 7, 9, 0, 0, 0, time.UTC)),
+			expected: []time.Time{
+				time.Date(2020, 1, 7, 9, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 4, 9, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 18, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "DTSTART with a time zone, across clocks being set forward",
+			rrule: "DTSTART;TZID=America/New_York:20200306T090000\r\nRRULE:FREQ=DAILY;COUNT=3",
+			expected: []time.Time{
+				time.Date(2020, 3, 6, 14, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 7, 14, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 8, 13, 0, 0, 0, time.UTC),
+			},
+			finite: true,
+		},
+	}
+
+	for _, test := range tests {
+		sched, err := newSchedule(&common.Schedule{
+			Spec:      &common.Schedule_Rrule{Rrule: test.rrule},
+			StartTime: test.start,
+		}, nil, "")
+		if err != nil {
+			t.Fatalf("case: %v. newSchedule: %v", test.name, err)
+		}
+
+		var prog progress
+		for _, expected := range test.expected {
+			next := sched(prog, time.Time{})
+			if next == nil || !next.at.Equal(expected) {
+				t.Errorf("case: %v. got fire %v, expected %v", test.name, next, expected)
+				break
 			}
+			prog.completedExecutions++
+			prog.lastScheduled = &next.slot
+		}
+
+		if next := sched(prog, time.Time{}); test.finite && next != nil {
+			t.Errorf("case: %v. got fire %v, expected none", test.name, next)
 		}
 	}
 }
 
+func TestInvalidRRule(t *testing.T) {
+	start := timestamppb.New(time.Unix(0, 0))
+
+	var tests = []struct {
+		name  string
+		rrule string
+		start *timestamppb.Timestamp
+	}{
+		{"missing property name", "FREQ=DAILY", start},
+		{"unknown frequency", "RRULE:FREQ=SOMETIMES", start},
+		{"only exceptions", "EXDATE:20200101T000000Z", start},
+		{"unsupported property", "RRULE:FREQ=DAILY\nX-FOO:bar", start},
+		{"DTSTART after RRULE", "RRULE:FREQ=DAILY\nDTSTART:20200101T000000Z", start},
+		{"no DTSTART or start time", "RRULE:FREQ=DAILY", nil},
+	}
+
+	for _, test := range tests {
+		_, err := newSchedule(&common.Schedule{
+			Spec:      &common.Schedule_Rrule{Rrule: test.rrule},
+			StartTime: test.start,
+		}, nil, "")
+		if err == nil {
+			t.Errorf("case: %v. expected error", test.name)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	jitter := 10 * time.Minute
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	offsets := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		timerUUID := fmt.Sprint("timer-", i)
+		sched, err := newSchedule(&common.Schedule{
+			Spec:          &common.Schedule_Cron{Cron: "@hourly"},
+			StartTime:     timestamppb.New(start),
+			Jitter:        durationpb.New(jitter),
+			MisfirePolicy: common.MisfirePolicy_MISFIRE_POLICY_SKIP,
+		}, nil, timerUUID)
+		if err != nil {
+			t.Fatalf("newSchedule: %v", err)
+		}
+
+		// a late node computes the same fire, as long as it isn't past the jittered time.
+		next := sched(progress{}, start)
+		offset := next.at.Sub(next.slot)
+		if late := sched(progress{}, next.at); late == nil || !late.at.Equal(next.at) {
+			t.Errorf("case: %v. got fire %v when late, expected %v", timerUUID, late, next)
+		}
+
+		if !next.slot.Equal(start) || offset < 0 || offset >= jitter {
+			t.Errorf("case: %v. got fire %v, expected slot %v with an offset in [0, %v)", timerUUID, next, start, jitter)
+		}
+		offsets[offset] = true
+
+		// the jitter doesn't accumulate, the next fire is for the next slot.
+		after := sched(progress{completedExecutions: 1, lastScheduled: &next.slot}, next.at)
+		if after == nil || !after.slot.Equal(start.Add(time.Hour)) {
+			t.Errorf("case: %v. got fire %v after %v, expected slot %v", timerUUID, after, next, start.Add(time.Hour))
+		}
+	}
+
+	if len(offsets) < 90 {
+		t.Errorf("got %v distinct offsets for 100 timers, expected them to be spread", len(offsets))
+	}
+}
+
+func TestInvalidJitter(t *testing.T) {
+	var tests = []struct {
+		name   string
+		spec   *common.Schedule
+		jitter time.Duration
+	}{
+		{"negative", &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@daily"}}, -time.Second},
+		{"over maximum", &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@daily"}}, maxJitter + time.Second},
+		{"period", &common.Schedule{Spec: &common.Schedule_Cron{Cron: "@every 10m"}}, 10 * time.Minute},
+		{"interval", &common.Schedule{Spec: &common.Schedule_Interval{Interval: durationpb.New(30 * time.Minute)}}, 45 * time.Minute},
+		// the shortest time between fires counts, not the first.
+		{"shortest period", &common.Schedule{Spec: &common.Schedule_Cron{Cron: "0,15 9 * * *"}}, 30 * time.Minute},
+	}
+
+	for _, test := range tests {
+		test.spec.StartTime = timestamppb.New(time.Unix(0, 0))
+		test.spec.Jitter = durationpb.New(test.jitter)
+		if _, err := newSchedule(test.spec, nil, ""); err == nil {
+			t.Errorf("case: %v. expected error for jitter %v", test.name, test.jitter)
+		}
+	}
+}
+
+func TestUnknownCalendar(t *testing.T) {
+	_, err := newSchedule(&common.Schedule{
+		Spec:      &common.Schedule_Cron{Cron: "@hourly"},
+		StartTime: timestamppb.New(time.Unix(0, 0)),
+		Calendars: []string{"holidays", "maintenance"},
+	}, []*common.Calendar{{Name: "holidays"}}, "")
+	if err == nil {
+		t.Error("expected error for unresolved calendar")
+	}
+}
+
+func TestInvalidTimezone(t *testing.T) {
+	_, err := newSchedule(&common.Schedule{
+		Spec:      &common.Schedule_Cron{Cron: "0 9 * * *"},
+		StartTime: timestamppb.New(time.Unix(0, 0)),
+		Timezone:  "America/Nowhere",
+	}, nil, "")
+	if err == nil {
+		t.Error("expected error for invalid timezone")
+	}
+}
+
+func TestNextFire(t *testing.T) {
+	create := &messaging.Create{
+		Schedule: &common.Schedule{
+			Spec:          &common.Schedule_Interval{Interval: durationpb.New(time.Minute)},
+			StartTime:     timestamppb.New(time.Unix(60, 0)),
+			MaxExecutions: 3,
+			Calendars:     []string{"maintenance"},
+		},
+		Calendars: []*common.Calendar{{
+			Name: "maintenance",
+			Ranges: []*common.TimeRange{{
+				Start: timestamppb.New(time.Unix(100, 0)),
+				End:   timestamppb.New(time.Unix(200, 0)),
+			}},
+		}},
+	}
+
+	var tests = []struct {
+		name     string
+		prog     *messaging.Progress
+		now      time.Time
+		expected time.Time
+	}{
+		{"first", nil, time.Unix(0, 0), time.Unix(60, 0)},
+		{"skips excluded", &messaging.Progress{CompletedExecutions: 1, LastScheduled: timestamppb.New(time.Unix(60, 0))}, time.Unix(60, 0), time.Unix(240, 0)},
+		{"finished", &messaging.Progress{CompletedExecutions: 3, LastScheduled: timestamppb.New(time.Unix(300, 0))}, time.Unix(300, 0), time.Time{}},
+	}
+
+	for _, test := range tests {
+		next, err := NextFire(create, test.prog, test.now)
+		if err != nil {
+			t.Errorf("case: %v. unexpected error: %v", test.name, err)
+		} else if !next.Equal(test.expected) {
+			t.Errorf("case: %v. got %v, expected %v", test.name, next, test.expected)
+		}
+	}
+}
+
+func TestCurrentProgress(t *testing.T) {
+	prog := &messaging.Progress{CompletedExecutions: 1, LastExecution: timestamppb.New(time.Unix(60, 0))}
+
+	reset := &messaging.Progress{LastScheduled: timestamppb.New(time.Unix(120, 0).Add(rollback))}
+
+	var tests = []struct {
+		name     string
+		create   *messaging.Create
+		prog     *messaging.Progress
+		expected *messaging.Progress
+	}{
+		{"not reset", &messaging.Create{Meta: &common.Meta{UpdateTime: timestamppb.New(time.Unix(120, 0))}}, prog, prog},
+		{"reset", &messaging.Create{ResetProgress: true, Meta: &common.Meta{UpdateTime: timestamppb.New(time.Unix(120, 0))}}, prog, reset},
+		{"reset without progress", &messaging.Create{ResetProgress: true, Meta: &common.Meta{UpdateTime: timestamppb.New(time.Unix(120, 0))}}, nil, reset},
+		{"executed after reset", &messaging.Create{ResetProgress: true, Meta: &common.Meta{UpdateTime: timestamppb.New(time.Unix(30, 0))}}, prog, prog},
+	}
+
+	for _, test := range tests {
+		if res := CurrentProgress(test.create, test.prog); !proto.Equal(res, test.expected) {
+			t.Errorf("case: %v. got %v, expected %v", test.name, res, test.expected)
+		}
+	}
+
+	// a reset timer continues from its update time, rather than missing every fire since it started.
+	create := &messaging.Create{
+		Schedule: &common.Schedule{
+			Spec:          &common.Schedule_Cron{Cron: "* * * * *"},
+			StartTime:     timestamppb.New(time.Unix(0, 0)),
+			MisfirePolicy: common.MisfirePolicy_MISFIRE_POLICY_FIRE_ALL,
+		},
+		ResetProgress: true,
+		Meta:          &common.Meta{UpdateTime: timestamppb.New(time.Unix(150, 0))},
+	}
+	next, err := NextFire(create, CurrentProgress(create, prog), time.Unix(150, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !next.Equal(time.Unix(180, 0)) {
+		t.Errorf("case: reset fire all. got %v, expected %v", next, time.Unix(180, 0))
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	ids := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids <- r.Header.Get("Execution-Id")
+	}))
+	defer server.Close()
+
+	create := &messaging.Create{
+		Task: &common.Task{Task: &common.Task_Http{Http: &common.HTTP{
+			Url:      server.URL,
+			Headers:  map[string]string{"Execution-Id": "{{execution_id}}"},
+			Template: true,
+		}}},
+		Schedule: &common.Schedule{
+			Spec:      &common.Schedule_Cron{Cron: "@yearly"},
+			StartTime: timestamppb.New(time.Unix(0, 0)),
+		},
+		Meta: &common.Meta{TimerUuid: "timer-uuid"},
+	}
+
+	res, err := Trigger(create, &messaging.Trigger{TriggerId: "trigger-id"}, clockwork.NewFakeClock(), nil)
+	if err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+
+	if id := <-ids; id != "timer-uuid-trigger-trigger-id" {
+		t.Errorf("got execution id %q", id)
+	}
+
+	if !res.Manual || res.TriggerId != "trigger-id" || res.Progress != nil || res.Outcome != messaging.Outcome_OUTCOME_SUCCESS {
+		t.Errorf("got execute %v", res)
+	}
+}
+
 func newTimerOptimistic(sched *common.Schedule, prog progress) *timer {
-	s, err := newSchedule(sched)
+	return newTimerOptimisticWithCalendars(sched, nil, prog)
+}
+
+func newTimerOptimisticWithCalendars(sched *common.Schedule, calendars []*common.Calendar, prog progress) *timer {
+	s, err := newSchedule(sched, calendars, "")
 	if err != nil {
 		panic(err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &timer{
-		execute:  func() []byte { return nil },
+		execute:  func(context.Context, execution) attempt { return attempt{} },
 		schedule: s,
 		progress: prog,
 		active:   atomic.NewBool(false),
 		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 